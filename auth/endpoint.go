@@ -3,26 +3,66 @@ package auth
 import (
 	"github.com/go-kit/kit/endpoint"
 	"context"
+	"time"
 	userModel "github.com/MICSTI/imsazon/models/user"
 )
 
 type loginRequest struct {
 	Username		string
 	Password		string
+	RemoteAddr		string
 }
 
 type loginResponse struct {
-	Token	string	`json:"token,omitempty"`
-	Err		error	`json:"error,omitempty"`
+	Token			string		`json:"token,omitempty"`
+	RefreshToken	string		`json:"refreshToken,omitempty"`
+	ExpiresAt		*time.Time	`json:"expiresAt,omitempty"`
+	Err				error		`json:"error,omitempty"`
 }
 
 func (r loginResponse) error() error { return r.Err }
 
+// creates the response for a login or a refresh
+func newLoginResponse(tokens *Tokens, err error) loginResponse {
+	if err != nil {
+		return loginResponse{Err: err}
+	}
+	return loginResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresAt: &tokens.ExpiresAt}
+}
+
 func makeLoginEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(loginRequest)
-		token, err := s.Login(req.Username, req.Password)
-		return loginResponse{Token: token, Err: err}, nil
+		return newLoginResponse(s.Login(req.Username, req.Password, req.RemoteAddr)), nil
+	}
+}
+
+type refreshRequest struct {
+	RefreshToken	string
+}
+
+func makeRefreshEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(refreshRequest)
+		return newLoginResponse(s.Refresh(req.RefreshToken)), nil
+	}
+}
+
+type logoutRequest struct {
+	RefreshToken	string
+}
+
+type logoutResponse struct {
+	Err		error	`json:"error,omitempty"`
+}
+
+func (r logoutResponse) error() error { return r.Err }
+
+func makeLogoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(logoutRequest)
+		err := s.Logout(TokenFromContext(ctx), req.RefreshToken)
+		return logoutResponse{Err: err}, nil
 	}
 }
 
@@ -32,6 +72,7 @@ type checkRequest struct {
 
 type checkResponse struct {
 	UserId	userModel.UserId	`json:"userId,omitempty"`
+	Role	string	`json:"role,omitempty"`
 	Err		error	`json:"error,omitempty"`
 }
 
@@ -40,7 +81,145 @@ func (r checkResponse) error() error { return r.Err }
 func makeCheckEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface {}) (interface{}, error) {
 		req := request.(checkRequest)
-		userId, err := s.Check(req.Token)
-		return checkResponse{UserId: userId, Err: err}, nil
+		claims, err := s.Check(req.Token)
+		if err != nil {
+			return checkResponse{Err: err}, nil
+		}
+		return checkResponse{UserId: userModel.UserId(claims.Subject), Role: claims.Role, Err: nil}, nil
+	}
+}
+
+type changePasswordRequest struct {
+	OldPassword		string
+	NewPassword		string
+}
+
+type changePasswordResponse struct {
+	Err		error	`json:"error,omitempty"`
+}
+
+func (r changePasswordResponse) error() error { return r.Err }
+
+func makeChangePasswordEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(changePasswordRequest)
+		err := s.ChangePassword(UserIdFromContext(ctx), req.OldPassword, req.NewPassword)
+		return changePasswordResponse{Err: err}, nil
+	}
+}
+
+type registerRequest struct {
+	Name			string
+	Email			string
+	Username		string
+	Password		string
+}
+
+type profileResponse struct {
+	Profile		*userModel.Profile	`json:"user,omitempty"`
+	Err			error				`json:"error,omitempty"`
+}
+
+func (r profileResponse) error() error { return r.Err }
+
+func makeRegisterEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(registerRequest)
+		profile, err := s.Register(req.Name, req.Email, req.Username, req.Password)
+		return profileResponse{Profile: profile, Err: err}, nil
+	}
+}
+
+type getProfileRequest struct {
+
+}
+
+func makeGetProfileEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		profile, err := s.GetProfile(UserIdFromContext(ctx))
+		return profileResponse{Profile: profile, Err: err}, nil
+	}
+}
+
+type updateProfileRequest struct {
+	Name			string
+	Email			string
+}
+
+func makeUpdateProfileEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateProfileRequest)
+		profile, err := s.UpdateProfile(UserIdFromContext(ctx), req.Name, req.Email)
+		return profileResponse{Profile: profile, Err: err}, nil
+	}
+}
+
+type listUsersRequest struct {
+
+}
+
+type listUsersResponse struct {
+	Users		[]*userModel.Profile	`json:"users"`
+}
+
+func makeListUsersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return listUsersResponse{Users: s.ListUsers()}, nil
+	}
+}
+
+type setRoleRequest struct {
+	UserId		userModel.UserId
+	Role		userModel.UserRole
+}
+
+func makeSetRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(setRoleRequest)
+		profile, err := s.SetRole(req.UserId, req.Role)
+		return profileResponse{Profile: profile, Err: err}, nil
+	}
+}
+
+type jwksRequest struct {
+
+}
+
+func makeJWKSEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return s.JWKS(), nil
+	}
+}
+
+type listLockoutsRequest struct {
+
+}
+
+type listLockoutsResponse struct {
+	Lockouts		[]*Lockout		`json:"lockouts"`
+}
+
+func makeListLockoutsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return listLockoutsResponse{Lockouts: s.ListLockouts()}, nil
+	}
+}
+
+type clearLockoutRequest struct {
+	Type			LockoutType
+	Value			string
+}
+
+type clearLockoutResponse struct {
+	Err				error			`json:"error,omitempty"`
+}
+
+func (r clearLockoutResponse) error() error { return r.Err }
+
+func makeClearLockoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(clearLockoutRequest)
+		err := s.ClearLockout(req.Type, req.Value)
+		return clearLockoutResponse{Err: err}, nil
 	}
 }
\ No newline at end of file
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrUnsupportedKey is returned for keys that are neither RSA nor ECDSA P-256 keys
+var ErrUnsupportedKey = errors.New("Unsupported key type")
+
+// ErrUnknownKey is returned when there is no key with the requested key id
+var ErrUnknownKey = errors.New("Unknown key id")
+
+// ErrNoPrivateKey is returned when the active key can't be used for signing because its private key is missing
+var ErrNoPrivateKey = errors.New("Active key has no private key")
+
+// Key is a key for signing or verifying JWT auth tokens, identified by its key id
+// keys without a private key can only be used for verifying tokens
+type Key struct {
+	Id				string
+	Method			jwt.SigningMethod
+	PublicKey		crypto.PublicKey
+	privateKey		crypto.Signer
+}
+
+// NewKey creates a key for signing and verifying JWT auth tokens out of an RSA (RS256) or ECDSA P-256 (ES256) private key
+func NewKey(id string, privateKey crypto.Signer) (*Key, error) {
+	method, err := signingMethodFor(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Id:				id,
+		Method:			method,
+		PublicKey:		privateKey.Public(),
+		privateKey:		privateKey,
+	}, nil
+}
+
+// NewVerificationKey creates a key that is only used for verifying JWT auth tokens, e.g. a retired signing key
+func NewVerificationKey(id string, publicKey crypto.PublicKey) (*Key, error) {
+	method, err := signingMethodFor(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Id:				id,
+		Method:			method,
+		PublicKey:		publicKey,
+	}, nil
+}
+
+func signingMethodFor(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return jwt.SigningMethodES256, nil
+		}
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// KeySet contains all keys that are accepted for verifying JWT auth tokens and the active key used for signing new ones
+// keys can be rotated by adding a new key, making it the active one and keeping the old one until all tokens signed with it have expired
+type KeySet struct {
+	active			*Key
+	keys			map[string]*Key
+}
+
+// NewKeySet creates a key set out of the passed keys, the key with the active id is used for signing
+func NewKeySet(activeId string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{
+		keys: make(map[string]*Key),
+	}
+
+	for _, k := range keys {
+		ks.keys[k.Id] = k
+	}
+
+	active, ok := ks.keys[activeId]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if active.privateKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	ks.active = active
+
+	return ks, nil
+}
+
+// LoadKeySet reads all PEM encoded keys from the directory, the file name without the extension is used as key id
+// private keys (PKCS #1, PKCS #8 or SEC 1) can be used for signing, public keys (PKIX) only for verifying
+func LoadKeySet(dir string, activeId string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*Key{}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+		k, err := parseKey(id, data)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return NewKeySet(activeId, keys...)
+}
+
+// GenerateKeySet creates a key set with a single random ES256 key
+// the key only lives in memory, so all tokens become invalid once the application is restarted
+func GenerateKeySet() (*KeySet, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := NewKey("generated", privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(k.Id, k)
+}
+
+func parseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrUnsupportedKey
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(id, privateKey)
+	case "EC PRIVATE KEY":
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(id, privateKey)
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := privateKey.(crypto.Signer); ok {
+			return NewKey(id, signer)
+		}
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerificationKey(id, publicKey)
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// sign creates a JWT auth token signed with the active key
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.Id
+	return token.SignedString(ks.active.privateKey)
+}
+
+// keyFunc looks up the key for verifying a token by the key id in its header
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	id, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	k, ok := ks.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	// never let the token choose the algorithm, otherwise an RSA public key could be used as HMAC secret
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, ErrUnsupportedKey
+	}
+
+	return k.PublicKey, nil
+}
+
+// JSONWebKey is the public part of a key in the JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType			string		`json:"kty"`
+	KeyId			string		`json:"kid"`
+	Use				string		`json:"use"`
+	Algorithm		string		`json:"alg"`
+	N				string		`json:"n,omitempty"`
+	E				string		`json:"e,omitempty"`
+	Curve			string		`json:"crv,omitempty"`
+	X				string		`json:"x,omitempty"`
+	Y				string		`json:"y,omitempty"`
+}
+
+// JSONWebKeySet contains the public keys other services need for verifying JWT auth tokens
+type JSONWebKeySet struct {
+	Keys			[]JSONWebKey	`json:"keys"`
+}
+
+// JWKS returns the public keys of the key set in the JWK format
+func (ks *KeySet) JWKS() *JSONWebKeySet {
+	jwks := &JSONWebKeySet{
+		Keys: make([]JSONWebKey, 0, len(ks.keys)),
+	}
+
+	for _, k := range ks.keys {
+		jwk := JSONWebKey{
+			KeyId:			k.Id,
+			Use:			"sig",
+			Algorithm:		k.Method.Alg(),
+		}
+
+		switch publicKey := k.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encodeBigInt(publicKey.N, 0)
+			jwk.E = encodeBigInt(big.NewInt(int64(publicKey.E)), 0)
+		case *ecdsa.PublicKey:
+			size := (publicKey.Curve.Params().BitSize + 7) / 8
+			jwk.KeyType = "EC"
+			jwk.Curve = publicKey.Curve.Params().Name
+			jwk.X = encodeBigInt(publicKey.X, size)
+			jwk.Y = encodeBigInt(publicKey.Y, size)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	// sort keys by id so always the same order will be returned
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyId < jwks.Keys[j].KeyId
+	})
+
+	return jwks
+}
+
+// encodes the number as unpadded base64url, left-padded with zeros to size bytes
+func encodeBigInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size - len(b)), b...)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
\ No newline at end of file
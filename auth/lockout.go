@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned when there were too many failed login attempts for the username or the remote address
+var ErrLocked = errors.New("Too many failed login attempts, try again later")
+
+// ErrUnknownLockout is returned when trying to clear a lockout that does not exist
+var ErrUnknownLockout = errors.New("Unknown lockout")
+
+// LockoutType describes what the failed login attempts are tracked for
+type LockoutType string
+
+const (
+	UsernameLockout		LockoutType = "username"
+	AddressLockout		LockoutType = "address"
+)
+
+// Lockout contains the failed login attempts for a username or a remote address
+type Lockout struct {
+	Type			LockoutType		`json:"type"`
+	Value			string			`json:"value"`
+	Failures		int				`json:"failures"`
+	LastFailure		time.Time		`json:"lastFailure"`
+	LockedUntil		time.Time		`json:"lockedUntil"`
+}
+
+// after the free attempts, every failed attempt doubles the lockout duration up to the maximum
+// failures are forgotten once there was no failed attempt for the reset window
+const (
+	freeLoginAttempts = 3
+	baseLockoutDuration = time.Second
+	maxLockoutDuration = time.Minute * 15
+	failureResetWindow = time.Hour
+)
+
+type lockoutKey struct {
+	Type			LockoutType
+	Value			string
+}
+
+type lockoutTracker struct {
+	mtx				sync.Mutex
+	lockouts		map[lockoutKey]*Lockout
+}
+
+func newLockoutTracker() *lockoutTracker {
+	return &lockoutTracker{
+		lockouts: make(map[lockoutKey]*Lockout),
+	}
+}
+
+// reserves a login attempt - if none of the keys is locked, the attempt is counted as failure right away,
+// so parallel attempts can't all pass the check before the first failure has been recorded
+// returns false if at least one of the keys is currently locked
+func (t *lockoutTracker) reserve(now time.Time, keys ...lockoutKey) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.prune(now)
+
+	for _, key := range keys {
+		if l, ok := t.lockouts[key]; ok && now.Before(l.LockedUntil) {
+			return false
+		}
+	}
+
+	for _, key := range keys {
+		l, ok := t.lockouts[key]
+		if !ok {
+			l = &Lockout{Type: key.Type, Value: key.Value}
+			t.lockouts[key] = l
+		}
+
+		l.Failures++
+		l.LastFailure = now
+
+		if l.Failures >= freeLoginAttempts {
+			l.LockedUntil = now.Add(lockoutDuration(l.Failures))
+		}
+	}
+
+	return true
+}
+
+// takes back a reserved attempt that has not failed
+func (t *lockoutTracker) release(keys ...lockoutKey) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, key := range keys {
+		l, ok := t.lockouts[key]
+		if !ok {
+			continue
+		}
+
+		l.Failures--
+
+		if l.Failures < freeLoginAttempts {
+			l.LockedUntil = time.Time{}
+		}
+
+		if l.Failures <= 0 {
+			delete(t.lockouts, key)
+		}
+	}
+}
+
+// removes the key, returns false if there was nothing to remove
+func (t *lockoutTracker) reset(key lockoutKey) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if _, ok := t.lockouts[key]; ok {
+		delete(t.lockouts, key)
+		return true
+	}
+	return false
+}
+
+// returns copies of all tracked lockouts, sorted by type and value
+func (t *lockoutTracker) list(now time.Time) []*Lockout {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.prune(now)
+
+	lockouts := make([]*Lockout, 0, len(t.lockouts))
+	for _, l := range t.lockouts {
+		copied := *l
+		lockouts = append(lockouts, &copied)
+	}
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		if lockouts[i].Type != lockouts[j].Type {
+			return lockouts[i].Type < lockouts[j].Type
+		}
+		return lockouts[i].Value < lockouts[j].Value
+	})
+
+	return lockouts
+}
+
+// forgets all entries that are not locked anymore and had no failure within the reset window
+// the caller has to hold the lock
+func (t *lockoutTracker) prune(now time.Time) {
+	for key, l := range t.lockouts {
+		if now.After(l.LockedUntil) && now.Sub(l.LastFailure) > failureResetWindow {
+			delete(t.lockouts, key)
+		}
+	}
+}
+
+func lockoutDuration(failures int) time.Duration {
+	exponent := uint(failures - freeLoginAttempts)
+
+	// avoid overflowing the duration, the maximum is reached long before anyway
+	if exponent > 20 {
+		return maxLockoutDuration
+	}
+
+	d := baseLockoutDuration << exponent
+	if d > maxLockoutDuration {
+		return maxLockoutDuration
+	}
+	return d
+}
\ No newline at end of file
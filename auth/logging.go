@@ -16,20 +16,47 @@ func NewLoggingService(logger log.Logger, s Service) Service {
 	return &loggingService{logger, s}
 }
 
-func (s *loggingService) Login(username string, password string) (signedToken string, err error) {
+func (s *loggingService) Login(username string, password string, remoteAddr string) (tokens *Tokens, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "Login",
 			"username", username,
+			"remoteAddr", remoteAddr,
 			"took", time.Since(begin),
 			"err", err,
 		)
 	}(time.Now())
-	return s.Service.Login(username, password)
+	return s.Service.Login(username, password, remoteAddr)
 }
 
-func (s *loggingService) Check(tokenString string) (userId userModel.UserId, err error) {
+func (s *loggingService) Refresh(refreshToken string) (tokens *Tokens, err error) {
 	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Refresh",
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Refresh(refreshToken)
+}
+
+func (s *loggingService) Logout(tokenString string, refreshToken string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Logout",
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Logout(tokenString, refreshToken)
+}
+
+func (s *loggingService) Check(tokenString string) (claims *CustomClaims, err error) {
+	defer func(begin time.Time) {
+		var userId userModel.UserId
+		if claims != nil {
+			userId = userModel.UserId(claims.Subject)
+		}
 		s.logger.Log(
 			"method", "Check",
 			"userId", userId,
@@ -38,4 +65,120 @@ func (s *loggingService) Check(tokenString string) (userId userModel.UserId, err
 		)
 	}(time.Now())
 	return s.Service.Check(tokenString)
+}
+
+func (s *loggingService) ChangePassword(userId userModel.UserId, oldPassword string, newPassword string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ChangePassword",
+			"userId", userId,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.ChangePassword(userId, oldPassword, newPassword)
+}
+
+func (s *loggingService) Register(name string, email string, username string, password string) (profile *userModel.Profile, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Register",
+			"username", username,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Register(name, email, username, password)
+}
+
+func (s *loggingService) GetProfile(userId userModel.UserId) (profile *userModel.Profile, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetProfile",
+			"userId", userId,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.GetProfile(userId)
+}
+
+func (s *loggingService) UpdateProfile(userId userModel.UserId, name string, email string) (profile *userModel.Profile, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "UpdateProfile",
+			"userId", userId,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.UpdateProfile(userId, name, email)
+}
+
+func (s *loggingService) ListUsers() []*userModel.Profile {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ListUsers",
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.ListUsers()
+}
+
+func (s *loggingService) SetRole(userId userModel.UserId, role userModel.UserRole) (profile *userModel.Profile, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "SetRole",
+			"userId", userId,
+			"role", role.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.SetRole(userId, role)
+}
+
+func (s *loggingService) ListLockouts() []*Lockout {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ListLockouts",
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.ListLockouts()
+}
+
+func (s *loggingService) ClearLockout(lockoutType LockoutType, value string) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ClearLockout",
+			"type", lockoutType,
+			"value", value,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.ClearLockout(lockoutType, value)
+}
+
+func (s *loggingService) JWKS() *JSONWebKeySet {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "JWKS",
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.JWKS()
+}
+
+func (s *loggingService) ServiceToken(name string) (signedToken string, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "ServiceToken",
+			"name", name,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.ServiceToken(name)
 }
\ No newline at end of file
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	userModel "github.com/MICSTI/imsazon/models/user"
+)
+
+type contextKey int
+
+const (
+	tokenContextKey contextKey = iota
+	userIdContextKey
+	roleContextKey
+)
+
+const bearerPrefix = "Bearer "
+
+// HTTPToContext moves the JWT auth token from the "Authorization: Bearer <token>" header into the request context
+func HTTPToContext() kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		header := r.Header.Get("Authorization")
+
+		if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+			return ctx
+		}
+
+		return context.WithValue(ctx, tokenContextKey, strings.TrimSpace(header[len(bearerPrefix):]))
+	}
+}
+
+// NewAuthenticationMiddleware returns an endpoint middleware that rejects requests without a valid JWT auth token
+// the UserId and the role of the token are put into the context, so the endpoints can use them instead of client-supplied values
+func NewAuthenticationMiddleware(s Service) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			token, ok := ctx.Value(tokenContextKey).(string)
+
+			if !ok || token == "" {
+				return nil, ErrMissingToken
+			}
+
+			claims, err := s.Check(token)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ctx = context.WithValue(ctx, userIdContextKey, userModel.UserId(claims.Subject))
+			ctx = context.WithValue(ctx, roleContextKey, userModel.ParseUserRole(claims.Role))
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// TokenFromContext returns the raw JWT auth token of the request
+func TokenFromContext(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenContextKey).(string); ok {
+		return token
+	}
+	return ""
+}
+
+// UserIdFromContext returns the UserId of the authenticated user
+func UserIdFromContext(ctx context.Context) userModel.UserId {
+	if userId, ok := ctx.Value(userIdContextKey).(userModel.UserId); ok {
+		return userId
+	}
+	return ""
+}
+
+// RoleFromContext returns the role of the authenticated user
+func RoleFromContext(ctx context.Context) userModel.UserRole {
+	if role, ok := ctx.Value(roleContextKey).(userModel.UserRole); ok {
+		return role
+	}
+	return userModel.Nobody
+}
\ No newline at end of file
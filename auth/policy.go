@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/go-kit/kit/endpoint"
+	userModel "github.com/MICSTI/imsazon/models/user"
+)
+
+// ErrForbidden is returned when the authenticated user is not allowed to perform an operation
+var ErrForbidden = errors.New("Forbidden")
+
+// NewAuthorizationMiddleware returns an endpoint middleware that only lets users with one of the passed roles through
+// it relies on the role put into the context by the authentication middleware, so it has to be wrapped by it
+func NewAuthorizationMiddleware(roles ...userModel.UserRole) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			role := RoleFromContext(ctx)
+
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(ctx, request)
+				}
+			}
+
+			return nil, ErrForbidden
+		}
+	}
+}
+
+// IsPrivileged returns true if the authenticated user is an admin or another service
+func IsPrivileged(ctx context.Context) bool {
+	role := RoleFromContext(ctx)
+	return role == userModel.Admin || role == userModel.Service
+}
+
+// CanAccess returns true if the authenticated user is allowed to access data that belongs to the passed user
+// privileged users can access everything, all others only their own data
+func CanAccess(ctx context.Context, owner userModel.UserId) bool {
+	if IsPrivileged(ctx) {
+		return true
+	}
+
+	userId := UserIdFromContext(ctx)
+
+	return userId != "" && userId == owner
+}
\ No newline at end of file
@@ -1,13 +1,23 @@
 /*
 	The auth service issues a JWT auth token for authentication inside the microservice architecture
+	The tokens are signed with asymmetric keys (RS256 or ES256), so other services only need the public keys
+	from the JWKS endpoint for verifying them.
  */
 
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"net/mail"
+	"strings"
 	userModel "github.com/MICSTI/imsazon/models/user"
+	tokenModel "github.com/MICSTI/imsazon/models/token"
 	"github.com/dgrijalva/jwt-go"
+	"sort"
 	"time"
 )
 
@@ -20,18 +30,83 @@ var ErrExpired = errors.New("Expired JWT")
 // ErrInvalid is returned when the JWT is not valid
 var ErrInvalid = errors.New("Invalid JWT")
 
+// ErrWrongPassword is returned when the current password passed for changing the password does not match
+var ErrWrongPassword = errors.New("Wrong password")
+
+// ErrInvalidRefreshToken is returned when the refresh token is unknown, has already been used or has expired
+var ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+
+// ErrMissingToken is returned when a request to a protected route does not contain a JWT
+var ErrMissingToken = errors.New("Missing JWT")
+
 // Service is the interface that provides the methods for obtaining an auth token
 type Service interface {
-	// Login checks the passed credentials and issues a JWT auth token in case they are valid
-	Login(username string, password string) (string, error)
+	// Login checks the passed credentials and issues a JWT auth token and a refresh token in case they are valid
+	// repeated failed attempts lock the username and the remote address for an exponentially growing duration
+	Login(username string, password string, remoteAddr string) (*Tokens, error)
+
+	// Refresh exchanges a refresh token for a new JWT auth token and a new refresh token
+	Refresh(refreshToken string) (*Tokens, error)
+
+	// Logout revokes the JWT auth token and, if passed, the refresh token of the session
+	Logout(token string, refreshToken string) error
+
+	// Check checks if the passed JWT auth token is valid and has not been revoked and returns its claims
+	Check(token string) (*CustomClaims, error)
+
+	// ChangePassword replaces the password of a user after checking the current one
+	ChangePassword(userId userModel.UserId, oldPassword string, newPassword string) error
+
+	// Register creates a new user with the Standard role
+	Register(name string, email string, username string, password string) (*userModel.Profile, error)
+
+	// GetProfile returns the profile of a user
+	GetProfile(userId userModel.UserId) (*userModel.Profile, error)
 
-	// Check checks if the passed JWT auth token is valid
-	Check(token string) (userModel.UserId, error)
+	// UpdateProfile changes the name and email address of a user
+	UpdateProfile(userId userModel.UserId, name string, email string) (*userModel.Profile, error)
+
+	// ListUsers returns the profiles of all users
+	ListUsers() []*userModel.Profile
+
+	// SetRole changes the role of a user
+	SetRole(userId userModel.UserId, role userModel.UserRole) (*userModel.Profile, error)
+
+	// ListLockouts returns all usernames and remote addresses with failed login attempts
+	ListLockouts() []*Lockout
+
+	// ClearLockout forgets the failed login attempts of a username or a remote address
+	ClearLockout(lockoutType LockoutType, value string) error
+
+	// JWKS returns the public keys for verifying JWT auth tokens
+	JWKS() *JSONWebKeySet
+
+	// ServiceToken issues a short-lived JWT auth token with the Service role, used for calls between the services
+	ServiceToken(name string) (string, error)
 }
 
+// passwords shorter than this are rejected
+const minPasswordLength = 6
+
+// JWT auth tokens are short-lived, sessions are kept alive with refresh tokens
+const (
+	accessTokenLifetime = time.Minute * 15
+	refreshTokenLifetime = time.Hour * 24 * 30
+)
+
 type service struct {
-	jwtSecret	[]byte
-	users		userModel.Repository
+	keys			*KeySet
+	users			userModel.Repository
+	tokens			tokenModel.Repository
+	passwordCost	int
+	lockouts		*lockoutTracker
+}
+
+// Tokens contains everything a client needs to access the services and to renew its session
+type Tokens struct {
+	AccessToken			string
+	RefreshToken		string
+	ExpiresAt			time.Time
 }
 
 // create a custom JWT claims struct
@@ -41,73 +116,336 @@ type CustomClaims struct {
 	jwt.StandardClaims
 }
 
-func (s *service) Login(username string, password string) (string, error) {
+func (s *service) Login(username string, password string, remoteAddr string) (*Tokens, error) {
 	if username == "" || password == "" {
-		return "", ErrInvalidArgument
+		return nil, ErrInvalidArgument
+	}
+
+	usernameKey := lockoutKey{UsernameLockout, username}
+	addressKey := lockoutKey{AddressLockout, remoteAddr}
+
+	// while locked, not even the correct password is accepted - otherwise the lockout would not slow down guessing
+	// the attempt counts as failure until the password has been checked
+	if !s.lockouts.reserve(time.Now(), usernameKey, addressKey) {
+		return nil, ErrLocked
 	}
 
 	u, err := s.users.CheckLogin(username, password)
 	if err != nil {
-		return "", err
+		// only wrong credentials count as failed attempt
+		if err != userModel.ErrUnknown {
+			s.lockouts.release(usernameKey, addressKey)
+		}
+		return nil, err
+	}
+
+	// only the username is reset, an attacker could otherwise clear the failures of the address with an own account
+	s.lockouts.release(addressKey)
+	s.lockouts.reset(usernameKey)
+
+	// the plaintext password is only available now, so this is the time to upgrade hashes created with a lower cost
+	// a failed upgrade does not affect the login, it will simply be tried again the next time
+	if u.NeedsRehash(s.passwordCost) {
+		if hash, err := userModel.HashPassword(password, s.passwordCost); err == nil {
+			s.users.UpdatePassword(u.Id, hash)
+		}
+	}
+
+	return s.issueTokens(u)
+}
+
+func (s *service) Refresh(refreshToken string) (*Tokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	// the refresh token is taken out of the store, so it can't be used a second time
+	stored, err := s.tokens.TakeRefreshToken(hashRefreshToken(refreshToken))
+	if err != nil || stored.Expired() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// load the user again, so changes to the name or the role are picked up
+	u, err := s.users.Find(stored.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(u)
+}
+
+func (s *service) Logout(tokenString string, refreshToken string) error {
+	claims, err := s.Check(tokenString)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokens.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
 	}
 
+	// whoever holds the refresh token could use it anyway, so there is no need to check its owner
+	if refreshToken != "" {
+		s.tokens.TakeRefreshToken(hashRefreshToken(refreshToken))
+	}
+
+	return nil
+}
+
+// issues a new JWT auth token and refresh token for the user
+func (s *service) issueTokens(u *userModel.User) (*Tokens, error) {
+	expiresAt := time.Now().Add(accessTokenLifetime)
+
 	// create the claims
 	claims := CustomClaims{
 		u.Role.String(),
 		u.Name,
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
+			Id: randomString(16),
+			ExpiresAt: expiresAt.Unix(),
 			Issuer: "imsazon",
 			Subject: u.Id.String(),
 		},
 	}
 
 	// create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, _ := token.SignedString(s.jwtSecret)
+	signedToken, err := s.keys.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := randomString(32)
+
+	err = s.tokens.StoreRefreshToken(tokenModel.New(hashRefreshToken(refreshToken), u.Id, time.Now().Add(refreshTokenLifetime)))
+	if err != nil {
+		return nil, err
+	}
 
-	return signedToken, nil
+	return &Tokens{
+		AccessToken:	signedToken,
+		RefreshToken:	refreshToken,
+		ExpiresAt:		expiresAt,
+	}, nil
 }
 
-func (s *service) Check(tokenString string) (userModel.UserId, error) {
-	if tokenString == "" {
-		return "", ErrInvalidArgument
+// returns a URL-safe random string made of n random bytes
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// only the hashes of the refresh tokens are stored
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *service) ChangePassword(userId userModel.UserId, oldPassword string, newPassword string) error {
+	if userId == "" || oldPassword == "" || len(newPassword) < minPasswordLength {
+		return ErrInvalidArgument
+	}
+
+	u, err := s.users.Find(userId)
+	if err != nil {
+		return err
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return s.jwtSecret, nil
+	if !u.CheckPassword(oldPassword) {
+		return ErrWrongPassword
+	}
+
+	hash, err := userModel.HashPassword(newPassword, s.passwordCost)
+	if err != nil {
+		return err
+	}
+
+	return s.users.UpdatePassword(userId, hash)
+}
+
+func (s *service) Register(name string, email string, username string, password string) (*userModel.Profile, error) {
+	name = strings.TrimSpace(name)
+	username = strings.TrimSpace(username)
+
+	if name == "" || username == "" || !isValidEmail(email) || len(password) < minPasswordLength {
+		return nil, ErrInvalidArgument
+	}
+
+	hash, err := userModel.HashPassword(password, s.passwordCost)
+	if err != nil {
+		return nil, err
+	}
+
+	u := userModel.New(userModel.NextUserId(), name, email, username, hash, userModel.Standard)
+
+	if err := s.users.Add(u); err != nil {
+		return nil, err
+	}
+
+	return u.Profile(), nil
+}
+
+func (s *service) GetProfile(userId userModel.UserId) (*userModel.Profile, error) {
+	if userId == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	u, err := s.users.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.Profile(), nil
+}
+
+func (s *service) UpdateProfile(userId userModel.UserId, name string, email string) (*userModel.Profile, error) {
+	name = strings.TrimSpace(name)
+
+	if userId == "" || name == "" || !isValidEmail(email) {
+		return nil, ErrInvalidArgument
+	}
+
+	u, err := s.users.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// work on a copy, so the stored user stays untouched if the update is rejected
+	updated := *u
+	updated.Name = name
+	updated.Email = email
+
+	if err := s.users.Update(&updated); err != nil {
+		return nil, err
+	}
+
+	return updated.Profile(), nil
+}
+
+func (s *service) ListUsers() []*userModel.Profile {
+	users := s.users.FindAll()
+
+	// sort users by ID so always the same order will be returned
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
 	})
 
+	profiles := make([]*userModel.Profile, 0, len(users))
+	for _, u := range users {
+		profiles = append(profiles, u.Profile())
+	}
+
+	return profiles
+}
+
+func (s *service) SetRole(userId userModel.UserId, role userModel.UserRole) (*userModel.Profile, error) {
+	if userId == "" || role.String() == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	u, err := s.users.Find(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *u
+	updated.Role = role
+
+	if err := s.users.Update(&updated); err != nil {
+		return nil, err
+	}
+
+	return updated.Profile(), nil
+}
+
+// only accepts plain addresses like "rey@jedi.com", without a display name
+func isValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+func (s *service) ServiceToken(name string) (string, error) {
+	if name == "" {
+		return "", ErrInvalidArgument
+	}
+
+	claims := CustomClaims{
+		userModel.Service.String(),
+		name,
+		jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute * 5).Unix(),
+			Issuer: "imsazon",
+			Subject: name,
+		},
+	}
+
+	return s.keys.sign(claims)
+}
+
+func (s *service) ListLockouts() []*Lockout {
+	return s.lockouts.list(time.Now())
+}
+
+func (s *service) ClearLockout(lockoutType LockoutType, value string) error {
+	if (lockoutType != UsernameLockout && lockoutType != AddressLockout) || value == "" {
+		return ErrInvalidArgument
+	}
+
+	if !s.lockouts.reset(lockoutKey{lockoutType, value}) {
+		return ErrUnknownLockout
+	}
+
+	return nil
+}
+
+func (s *service) JWKS() *JSONWebKeySet {
+	return s.keys.JWKS()
+}
+
+func (s *service) Check(tokenString string) (*CustomClaims, error) {
+	if tokenString == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	// tokens signed with any key of the key set are accepted, so keys can be rotated without invalidating all sessions
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, s.keys.keyFunc)
+
 	// for the case we just received a random string
 	if token == nil {
-		return "", ErrInvalid
+		return nil, ErrInvalid
 	}
 
 	if token.Valid {
 		if claims, ok := token.Claims.(*CustomClaims); ok {
-			userId := userModel.UserId(claims.Subject)
+			if claims.Id != "" && s.tokens.IsRevoked(claims.Id) {
+				return nil, ErrInvalid
+			}
 
-			return userId, nil
+			return claims, nil
 		} else {
-			return "", ErrInvalid
+			return nil, ErrInvalid
 		}
 	} else if ve, ok := err.(*jwt.ValidationError); ok {
 		if ve.Errors & jwt.ValidationErrorMalformed != 0 {
-			return "", ErrInvalid
+			return nil, ErrInvalid
 		} else if ve.Errors & (jwt.ValidationErrorExpired | jwt.ValidationErrorNotValidYet) != 0 {
-			return "", ErrExpired
+			return nil, ErrExpired
 		} else {
-			return "", ErrInvalid
+			return nil, ErrInvalid
 		}
 	}
 
-	return "", ErrInvalid
+	return nil, ErrInvalid
 }
 
 // NewService returns a new instance of the auth service
-func NewService(jwtSecret []byte, users userModel.Repository) Service {
+// passwordCost is the bcrypt cost used for new password hashes - existing hashes with a lower cost are upgraded on login
+func NewService(keys *KeySet, users userModel.Repository, tokens tokenModel.Repository, passwordCost int) Service {
 	return &service{
-		jwtSecret:	jwtSecret,
-		users:		users,
+		keys:			keys,
+		users:			users,
+		tokens:			tokens,
+		passwordCost:	passwordCost,
+		lockouts:		newLockoutTracker(),
 	}
 }
\ No newline at end of file
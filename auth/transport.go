@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"context"
 	"errors"
+	"io"
+	"net"
 	"github.com/gorilla/mux"
 	userModel "github.com/MICSTI/imsazon/models/user"
 )
@@ -16,8 +18,12 @@ func MakeHandler(as Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(HTTPToContext()),
 	}
 
+	authenticate := NewAuthenticationMiddleware(as)
+	authorize := NewAuthorizationMiddleware(userModel.Admin)
+
 	loginHandler := kithttp.NewServer(
 		makeLoginEndpoint(as),
 		decodeLoginRequest,
@@ -32,10 +38,98 @@ func MakeHandler(as Service, logger kitlog.Logger) http.Handler {
 		opts...
 	)
 
+	refreshHandler := kithttp.NewServer(
+		makeRefreshEndpoint(as),
+		decodeRefreshRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	logoutHandler := kithttp.NewServer(
+		authenticate(makeLogoutEndpoint(as)),
+		decodeLogoutRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	changePasswordHandler := kithttp.NewServer(
+		authenticate(makeChangePasswordEndpoint(as)),
+		decodeChangePasswordRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	listLockoutsHandler := kithttp.NewServer(
+		authenticate(authorize(makeListLockoutsEndpoint(as))),
+		decodeListLockoutsRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	clearLockoutHandler := kithttp.NewServer(
+		authenticate(authorize(makeClearLockoutEndpoint(as))),
+		decodeClearLockoutRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	jwksHandler := kithttp.NewServer(
+		makeJWKSEndpoint(as),
+		decodeJWKSRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	registerHandler := kithttp.NewServer(
+		makeRegisterEndpoint(as),
+		decodeRegisterRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getProfileHandler := kithttp.NewServer(
+		authenticate(makeGetProfileEndpoint(as)),
+		decodeGetProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	updateProfileHandler := kithttp.NewServer(
+		authenticate(makeUpdateProfileEndpoint(as)),
+		decodeUpdateProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	listUsersHandler := kithttp.NewServer(
+		authenticate(authorize(makeListUsersEndpoint(as))),
+		decodeListUsersRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	setRoleHandler := kithttp.NewServer(
+		authenticate(authorize(makeSetRoleEndpoint(as))),
+		decodeSetRoleRequest,
+		encodeResponse,
+		opts...,
+	)
+
 	r := mux.NewRouter()
 
 	r.Handle("/auth/login", loginHandler).Methods("POST")
 	r.Handle("/auth/check", checkHandler).Methods("POST")
+	r.Handle("/auth/refresh", refreshHandler).Methods("POST")
+	r.Handle("/auth/logout", logoutHandler).Methods("POST")
+	r.Handle("/auth/password", changePasswordHandler).Methods("POST")
+	r.Handle("/auth/lockouts", listLockoutsHandler).Methods("GET")
+	r.Handle("/auth/lockouts/clear", clearLockoutHandler).Methods("POST")
+	r.Handle("/auth/.well-known/jwks.json", jwksHandler).Methods("GET")
+	r.Handle("/auth/register", registerHandler).Methods("POST")
+	r.Handle("/auth/profile", getProfileHandler).Methods("GET")
+	r.Handle("/auth/profile", updateProfileHandler).Methods("POST")
+	r.Handle("/auth/users", listUsersHandler).Methods("GET")
+	r.Handle("/auth/users/{userId}/role", setRoleHandler).Methods("POST")
 
 	return r
 }
@@ -52,9 +146,16 @@ func decodeLoginRequest(_ context.Context, r *http.Request) (interface{}, error)
 		return nil, err
 	}
 
+	// the direct peer address is used on purpose - forwarding headers could be set to anything by the client
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteAddr = r.RemoteAddr
+	}
+
 	return loginRequest{
 		Username: 	body.Username,
 		Password:	body.Password,
+		RemoteAddr:	remoteAddr,
 	}, nil
 }
 
@@ -72,6 +173,150 @@ func decodeCheckRequest(_ context.Context, r *http.Request) (interface{}, error)
 	}, nil
 }
 
+func decodeRefreshRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		RefreshToken	string	`json:"refreshToken"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return refreshRequest{
+		RefreshToken:	body.RefreshToken,
+	}, nil
+}
+
+func decodeLogoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		RefreshToken	string	`json:"refreshToken"`
+	}
+
+	// the refresh token is optional, so an empty body is fine as well
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return logoutRequest{
+		RefreshToken:	body.RefreshToken,
+	}, nil
+}
+
+func decodeChangePasswordRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		OldPassword		string	`json:"oldPassword"`
+		NewPassword		string	`json:"newPassword"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return changePasswordRequest{
+		OldPassword:	body.OldPassword,
+		NewPassword:	body.NewPassword,
+	}, nil
+}
+
+func decodeListLockoutsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listLockoutsRequest{}, nil
+}
+
+func decodeClearLockoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Type		LockoutType	`json:"type"`
+		Value		string		`json:"value"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return clearLockoutRequest{
+		Type:		body.Type,
+		Value:		body.Value,
+	}, nil
+}
+
+func decodeJWKSRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	// there are no parameters to the request, so we don't need to decode anything
+	return jwksRequest{}, nil
+}
+
+func decodeRegisterRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Name		string	`json:"name"`
+		Email		string	`json:"email"`
+		Username	string	`json:"username"`
+		Password	string	`json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return registerRequest{
+		Name:		body.Name,
+		Email:		body.Email,
+		Username:	body.Username,
+		Password:	body.Password,
+	}, nil
+}
+
+func decodeGetProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	// the profile always belongs to the authenticated user, so there is nothing to decode here
+	return getProfileRequest{}, nil
+}
+
+func decodeUpdateProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Name		string	`json:"name"`
+		Email		string	`json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return updateProfileRequest{
+		Name:		body.Name,
+		Email:		body.Email,
+	}, nil
+}
+
+func decodeListUsersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return listUsersRequest{}, nil
+}
+
+func decodeSetRoleRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	userId, ok := vars["userId"]
+
+	if !ok {
+		return nil, errBadRoute
+	}
+
+	var body struct {
+		Role		string	`json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	// unknown role names would silently be mapped to Nobody otherwise
+	role := userModel.ParseUserRole(body.Role)
+	if role.String() != body.Role {
+		return nil, ErrInvalidArgument
+	}
+
+	return setRoleRequest{
+		UserId:		userModel.UserId(userId),
+		Role:		role,
+	}, nil
+}
+
 // encode the JSON response
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	if e, ok := response.(erroer); ok && e.error() != nil {
@@ -98,6 +343,24 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		w.WriteHeader(http.StatusForbidden)
 	case ErrExpired:
 		w.WriteHeader(http.StatusForbidden)
+	case ErrWrongPassword:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrInvalidRefreshToken:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	case userModel.ErrUsernameTaken:
+		w.WriteHeader(http.StatusConflict)
+	case userModel.ErrEmailTaken:
+		w.WriteHeader(http.StatusConflict)
+	case errBadRoute:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrLocked:
+		w.WriteHeader(http.StatusTooManyRequests)
+	case ErrUnknownLockout:
+		w.WriteHeader(http.StatusBadRequest)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -0,0 +1,245 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+	"go.etcd.io/bbolt"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+)
+
+// bucket names
+var (
+	metaBucket = []byte("meta")
+	usersBucket = []byte("users")
+	usernamesBucket = []byte("usernames")
+	productsBucket = []byte("products")
+	cartsBucket = []byte("carts")
+	ordersBucket = []byte("orders")
+	refreshTokensBucket = []byte("refreshTokens")
+	revokedTokensBucket = []byte("revokedTokens")
+	reservationsBucket = []byte("reservations")
+	categoriesBucket = []byte("categories")
+	returnsBucket = []byte("returns")
+	idempotencyBucket = []byte("idempotency")
+	paymentsBucket = []byte("payments")
+)
+
+var schemaVersionKey = []byte("schemaVersion")
+
+// migrations are applied in order, the schema version stored in the database is the number of applied migrations
+// existing migrations must never be changed - new ones are only appended
+var migrations = []func(tx *bbolt.Tx) error{
+	createBuckets,
+	loadSampleData,
+	createReservationsBucket,
+	createCategories,
+	rewriteProductPrices,
+	createReturnsBucket,
+	convertOrderDates,
+	createIdempotencyBucket,
+	createPaymentsBucket,
+}
+
+// brings the database to the latest schema version, all pending migrations are applied in a single transaction
+func migrate(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return err
+			}
+		}
+
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(version))
+		return meta.Put(schemaVersionKey, v)
+	})
+}
+
+func createBuckets(tx *bbolt.Tx) error {
+	for _, name := range [][]byte{usersBucket, usernamesBucket, productsBucket, cartsBucket, ordersBucket, refreshTokensBucket, revokedTokensBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the sample data is only loaded when the database is created, afterwards it belongs to the users
+// it is a literal snapshot in the format of that time, the later migrations convert it like any other existing data
+var sampleData = []struct {
+	bucket			[]byte
+	key				string
+	value			string
+}{
+	{usersBucket, "U0001", `{"Id":"U0001","Name":"Rey","Email":"rey@jedi.com","Username":"rey","PasswordHash":"$2a$10$eEl3Vbc6q8qcz1Jo1qyO3.cLbwqwwsavgbZ2bldOkvkcBp99NWBou","Role":1}`},
+	{usersBucket, "U0002", `{"Id":"U0002","Name":"Kylo","Email":"kylo@firstorder.com","Username":"kylo","PasswordHash":"$2a$10$KxSDflxe2md9nOSYo6jDi.2FuosuCeKv5hqxKwMbAa9p4dNo7WFeO","Role":1}`},
+	{usersBucket, "U0003", `{"Id":"U0003","Name":"Luke","Email":"luke@jedi.com","Username":"luke","PasswordHash":"$2a$10$2FxsLBVkQ6fNfBY.Qf3HTeTmYTJvFa8EVLqFKYIbp1fftssTT59Tu","Role":2}`},
+	{usernamesBucket, "rey", "U0001"},
+	{usernamesBucket, "kylo", "U0002"},
+	{usernamesBucket, "luke", "U0003"},
+	{productsBucket, "P0001", `{"id":"P0001","name":"Lightsaber","description":"The perfect lightsaber for every aspiring Jedi","category":"Weapons","imageUrl":"http://images.buystarwarstoys.com/products/9288/1-1/ahsoka-tano-toy-lightsaber.jpg","price":999.99,"quantity":10}`},
+	{productsBucket, "P0002", `{"id":"P0002","name":"The Millenium Falcon","description":"The fastest ship in the entire gallaxy - finished the Kessel Run in less than 12 parsecs","category":"Mobility","imageUrl":"http://ksassets.timeincuk.net/wp/uploads/sites/54/2017/11/Millenium-Falcon.jpg","price":30000,"quantity":1}`},
+	{productsBucket, "P0003", `{"id":"P0003","name":"BB 8","description":"Extraordinarily helpful droid","category":"Droids","imageUrl":"https://images.fun.com/products/34909/2-1-63328/star-wars-episode-7-rey-jakku-and-bb8-black-series-set.jpg","price":12499,"quantity":3}`},
+	{productsBucket, "P0004", `{"id":"P0004","name":"Podracer","description":"Lightning-fast podracer - nobody will be able to beat you","category":"Mobility","imageUrl":"https://images-na.ssl-images-amazon.com/images/I/41j3vMHSX0L._AA300_.jpg","price":3499,"quantity":6}`},
+	{productsBucket, "P0005", `{"id":"P0005","name":"Carbonite Freezer","description":"Very useful in case you need to freeze someone in carbonite","category":"Utilities","imageUrl":"https://s-i.huffpost.com/gen/1359887/images/o-HAN-SOLO-CARBONITE-facebook.jpg","price":39999.99,"quantity":2}`},
+	{ordersBucket, "eVDzWBQPrRSfUHhq", `{"id":"eVDzWBQPrRSfUHhq","userId":"U0001","date":"18.01.2018","status":3,"items":[{"id":"P0001","quantity":2},{"id":"P0003","quantity":1}]}`},
+	{ordersBucket, "dcIFBYphdHhymCUT", `{"id":"dcIFBYphdHhymCUT","userId":"U0003","date":"21.01.2018","status":5,"items":[{"id":"P0002","quantity":1}]}`},
+}
+
+func loadSampleData(tx *bbolt.Tx) error {
+	for _, entry := range sampleData {
+		if err := tx.Bucket(entry.bucket).Put([]byte(entry.key), []byte(entry.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createReservationsBucket(tx *bbolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(reservationsBucket)
+	return err
+}
+
+// adds the sample categories and points the products to them - until now the category of a product was its name
+func createCategories(tx *bbolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(categoriesBucket); err != nil {
+		return err
+	}
+
+	ids := make(map[string]categoryModel.CategoryId)
+	for _, c := range []*categoryModel.Category{categoryModel.Equipment, categoryModel.Weapons, categoryModel.Utilities, categoryModel.Mobility, categoryModel.Droids} {
+		if err := put(tx, categoriesBucket, c.Id.String(), c); err != nil {
+			return err
+		}
+		ids[c.Name] = c.Id
+		ids[c.Id.String()] = c.Id
+	}
+
+	products := tx.Bucket(productsBucket)
+	updated := []*productModel.Product{}
+	err := products.ForEach(func(k, v []byte) error {
+		var p productModel.Product
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+
+		id, ok := ids[p.Category]
+		if !ok {
+			// unknown names get their own top level category, so no product loses its category
+			id = categoryModel.IdFromName(p.Category)
+			if id != "" && tx.Bucket(categoriesBucket).Get([]byte(id)) == nil {
+				if err := put(tx, categoriesBucket, id.String(), categoryModel.New(id, p.Category, "")); err != nil {
+					return err
+				}
+			}
+			ids[p.Category] = id
+		}
+
+		p.Category = id.String()
+		updated = append(updated, &p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// keys must not be changed while iterating over the bucket
+	for _, p := range updated {
+		if err := put(tx, productsBucket, p.Id.String(), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prices used to be stored as floating point numbers, reading them converts them into money amounts in the default currency
+// writing them back stores them in the new format, so the conversion only happens once
+func rewriteProductPrices(tx *bbolt.Tx) error {
+	products := tx.Bucket(productsBucket)
+	updated := []*productModel.Product{}
+	err := products.ForEach(func(k, v []byte) error {
+		var p productModel.Product
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+		updated = append(updated, &p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// keys must not be changed while iterating over the bucket
+	for _, p := range updated {
+		if err := put(tx, productsBucket, p.Id.String(), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createReturnsBucket(tx *bbolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(returnsBucket)
+	return err
+}
+
+// orders used to have a date string instead of timestamps
+// the timestamps are taken from the status history, older orders without history get the date at midnight UTC
+func convertOrderDates(tx *bbolt.Tx) error {
+	orders := tx.Bucket(ordersBucket)
+	updated := []*orderModel.Order{}
+	err := orders.ForEach(func(k, v []byte) error {
+		var o struct {
+			orderModel.Order
+			Date		string		`json:"date"`
+		}
+		if err := json.Unmarshal(v, &o); err != nil {
+			return err
+		}
+
+		if len(o.History) > 0 {
+			o.CreatedAt = o.History[0].ChangedAt
+			o.UpdatedAt = o.History[len(o.History) - 1].ChangedAt
+		} else if date, err := time.Parse("02.01.2006", o.Date); err == nil {
+			o.CreatedAt = date
+			o.UpdatedAt = date
+		}
+
+		updated = append(updated, &o.Order)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// keys must not be changed while iterating over the bucket
+	for _, o := range updated {
+		if err := put(tx, ordersBucket, o.Id.String(), o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createIdempotencyBucket(tx *bbolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(idempotencyBucket)
+	return err
+}
+
+func createPaymentsBucket(tx *bbolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(paymentsBucket)
+	return err
+}
\ No newline at end of file
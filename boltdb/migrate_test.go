@@ -0,0 +1,41 @@
+package boltdb
+
+import (
+	"testing"
+	"time"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	productModel "github.com/MICSTI/imsazon/models/product"
+)
+
+// the sample data is written in its original format, so a new database runs through the same conversions as an old one
+func TestMigrateConvertsSampleData(t *testing.T) {
+	db := openTestDB(t)
+
+	p, err := NewProductRepository(db).Find(productModel.P0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Category != categoryModel.Weapons.Id.String() {
+		t.Errorf("category = %q, want %q", p.Category, categoryModel.Weapons.Id)
+	}
+
+	if want := money.New(99999, money.EUR); p.Price != want {
+		t.Errorf("price = %v, want %v", p.Price, want)
+	}
+
+	o, err := NewOrderRepository(db).Find("eVDzWBQPrRSfUHhq")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2018, 1, 18, 0, 0, 0, 0, time.UTC); !o.CreatedAt.Equal(want) {
+		t.Errorf("createdAt = %v, want %v", o.CreatedAt, want)
+	}
+
+	if o.Status != orderModel.Shipped || len(o.Items) != 2 {
+		t.Errorf("order = %v with %d items, want %v with 2 items", o.Status, len(o.Items), orderModel.Shipped)
+	}
+}
\ No newline at end of file
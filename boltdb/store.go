@@ -0,0 +1,1180 @@
+/*
+	This package implements the repository stores on top of an embedded bbolt database, so all data survives a restart.
+	Every entity has its own bucket, the records are stored JSON encoded with their id as key.
+ */
+
+package boltdb
+
+import (
+	"encoding/json"
+	"time"
+	"go.etcd.io/bbolt"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	cartModel "github.com/MICSTI/imsazon/models/cart"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	tokenModel "github.com/MICSTI/imsazon/models/token"
+	idempotencyModel "github.com/MICSTI/imsazon/models/idempotency"
+)
+
+// Open opens the database file, creating it if necessary, and migrates it to the latest schema version
+func Open(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// reads and decodes a record, returns false if there is no record for the key
+func get(tx *bbolt.Tx, bucket []byte, key string, v interface{}) (bool, error) {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// encodes and writes a record
+func put(tx *bbolt.Tx, bucket []byte, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(key), data)
+}
+
+/* ---------- USER REPOSITORY ---------- */
+type userRepository struct {
+	db		*bbolt.DB
+}
+
+// only used for comparing passwords of unknown usernames
+var dummyUser = &userModel.User{PasswordHash: "$2a$10$eEl3Vbc6q8qcz1Jo1qyO3.cLbwqwwsavgbZ2bldOkvkcBp99NWBou"}
+
+// returns an error if another user already uses the email address
+func checkEmailAvailable(tx *bbolt.Tx, u *userModel.User) error {
+	return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+		var stored userModel.User
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return err
+		}
+		if stored.Id != u.Id && stored.Email == u.Email {
+			return userModel.ErrEmailTaken
+		}
+		return nil
+	})
+}
+
+// adds a user to the repository store
+func (r *userRepository) Add(u *userModel.User) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usernamesBucket).Get([]byte(u.Username)) != nil {
+			return userModel.ErrUsernameTaken
+		}
+		if err := checkEmailAvailable(tx, u); err != nil {
+			return err
+		}
+		if err := tx.Bucket(usernamesBucket).Put([]byte(u.Username), []byte(u.Id)); err != nil {
+			return err
+		}
+		return put(tx, usersBucket, u.Id.String(), u)
+	})
+}
+
+// replaces the stored data of an existing user
+func (r *userRepository) Update(u *userModel.User) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		var stored userModel.User
+		found, err := get(tx, usersBucket, u.Id.String(), &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return userModel.ErrUnknown
+		}
+		if err := checkEmailAvailable(tx, u); err != nil {
+			return err
+		}
+		if stored.Username != u.Username {
+			usernames := tx.Bucket(usernamesBucket)
+			if usernames.Get([]byte(u.Username)) != nil {
+				return userModel.ErrUsernameTaken
+			}
+			if err := usernames.Delete([]byte(stored.Username)); err != nil {
+				return err
+			}
+			if err := usernames.Put([]byte(u.Username), []byte(u.Id)); err != nil {
+				return err
+			}
+		}
+		return put(tx, usersBucket, u.Id.String(), u)
+	})
+}
+
+// attempts to find the user by UserId inside the repository store
+func (r *userRepository) Find(id userModel.UserId) (*userModel.User, error) {
+	var u userModel.User
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, usersBucket, id.String(), &u)
+		if err == nil && !found {
+			return userModel.ErrUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// returns alls users in an array
+func (r *userRepository) FindAll() []*userModel.User {
+	u := []*userModel.User{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var val userModel.User
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			u = append(u, &val)
+			return nil
+		})
+	})
+	return u
+}
+
+// checks the login credentials of a user
+func (r *userRepository) CheckLogin(username string, password string) (*userModel.User, error) {
+	var u userModel.User
+	found := false
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		id := tx.Bucket(usernamesBucket).Get([]byte(username))
+		if id == nil {
+			return nil
+		}
+		var err error
+		found, err = get(tx, usersBucket, string(id), &u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// compare against a dummy hash anyway so unknown usernames take as long as wrong passwords
+		dummyUser.CheckPassword(password)
+		return nil, userModel.ErrUnknown
+	}
+	if !u.CheckPassword(password) {
+		return nil, userModel.ErrUnknown
+	}
+	return &u, nil
+}
+
+// replaces the password hash of a user
+func (r *userRepository) UpdatePassword(id userModel.UserId, passwordHash string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		var u userModel.User
+		found, err := get(tx, usersBucket, id.String(), &u)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return userModel.ErrUnknown
+		}
+		u.PasswordHash = passwordHash
+		return put(tx, usersBucket, id.String(), &u)
+	})
+}
+
+// returns an instance of a user repository
+func NewUserRepository(db *bbolt.DB) userModel.Repository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+/* ---------- PRODUCT REPOSITORY ---------- */
+type productRepository struct {
+	db		*bbolt.DB
+}
+
+// returns the quantities held by active reservations per product
+func reservedQuantities(tx *bbolt.Tx, now time.Time) (map[productModel.ProductId]int, error) {
+	reservations := []*productModel.Reservation{}
+	err := tx.Bucket(reservationsBucket).ForEach(func(k, v []byte) error {
+		var res productModel.Reservation
+		if err := json.Unmarshal(v, &res); err != nil {
+			return err
+		}
+		reservations = append(reservations, &res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return productModel.ReservedQuantities(reservations, now), nil
+}
+
+// reads a product and fills in the quantity that is not held by active reservations
+func getProduct(tx *bbolt.Tx, id productModel.ProductId, reserved map[productModel.ProductId]int, p *productModel.Product) (bool, error) {
+	found, err := get(tx, productsBucket, id.String(), p)
+	if err != nil || !found {
+		return found, err
+	}
+	p.Available = p.Quantity - reserved[id]
+	return true, nil
+}
+
+// writes a product, the available quantity is always computed when reading
+func putProduct(tx *bbolt.Tx, p *productModel.Product) error {
+	stored := *p
+	stored.Available = 0
+	return put(tx, productsBucket, p.Id.String(), &stored)
+}
+
+func (r *productRepository) Store(p *productModel.Product) (*productModel.Product, error) {
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		return putProduct(tx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *productRepository) Update(p *productModel.Product) (*productModel.Product, error) {
+	var stored productModel.Product
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		found, err := getProduct(tx, p.Id, reserved, &stored)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrProductUnknown
+		}
+
+		stored.Name = p.Name
+		stored.Description = p.Description
+		stored.Category = p.Category
+		stored.ImageUrl = p.ImageUrl
+		stored.Price = p.Price
+
+		return putProduct(tx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *productRepository) Remove(id productModel.ProductId) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		products := tx.Bucket(productsBucket)
+		if products.Get([]byte(id)) == nil {
+			return productModel.ErrProductUnknown
+		}
+
+		if err := products.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		reservations := tx.Bucket(reservationsBucket)
+		removed := [][]byte{}
+		err := reservations.ForEach(func(k, v []byte) error {
+			var res productModel.Reservation
+			if err := json.Unmarshal(v, &res); err != nil {
+				return err
+			}
+			if res.ProductId == id {
+				removed = append(removed, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// keys must not be deleted while iterating over the bucket
+		for _, k := range removed {
+			if err := reservations.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *productRepository) Add(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	var stored productModel.Product
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		found, err := getProduct(tx, item.Id, reserved, &stored)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrProductUnknown
+		}
+
+		stored.Quantity += item.Quantity
+		stored.Available += item.Quantity
+
+		return putProduct(tx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *productRepository) Withdraw(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	var stored productModel.Product
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		found, err := getProduct(tx, item.Id, reserved, &stored)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrProductUnknown
+		}
+
+		// check if there are enough unreserved items for withdrawing
+		if stored.Available < item.Quantity {
+			return productModel.ErrNotEnoughItems
+		}
+
+		stored.Quantity -= item.Quantity
+		stored.Available -= item.Quantity
+
+		return putProduct(tx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *productRepository) WithdrawBatch(items []*productModel.SimpleProduct) ([]*productModel.Product, error) {
+	updated := []*productModel.Product{}
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// returning an error rolls back the whole transaction, so either all items are withdrawn or none
+		// the items are checked in the order of the request, so the first invalid item is always the one reported
+		for _, item := range productModel.MergeQuantities(items) {
+			var stored productModel.Product
+			found, err := getProduct(tx, item.Id, reserved, &stored)
+			if err != nil {
+				return err
+			}
+
+			if !found {
+				return productModel.ErrProductUnknown
+			}
+
+			if stored.Available < item.Quantity {
+				return productModel.ErrNotEnoughItems
+			}
+
+			stored.Quantity -= item.Quantity
+			stored.Available -= item.Quantity
+
+			if err := putProduct(tx, &stored); err != nil {
+				return err
+			}
+
+			updated = append(updated, &stored)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *productRepository) Reserve(res *productModel.Reservation) (*productModel.Reservation, error) {
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var stored productModel.Product
+		found, err := getProduct(tx, res.ProductId, reserved, &stored)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrProductUnknown
+		}
+
+		if stored.Available < res.Quantity {
+			return productModel.ErrNotEnoughItems
+		}
+
+		return put(tx, reservationsBucket, res.Id.String(), res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *productRepository) CommitReservation(id productModel.ReservationId) (*productModel.Product, error) {
+	var stored productModel.Product
+	expired := false
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		var res productModel.Reservation
+		found, err := get(tx, reservationsBucket, id.String(), &res)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrReservationUnknown
+		}
+
+		// the reservation is gone in any case - either it is turned into a withdrawal or it has expired
+		if err := tx.Bucket(reservationsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if res.Expired(now) {
+			// the removal of the expired reservation is still committed
+			expired = true
+			return nil
+		}
+
+		reserved, err := reservedQuantities(tx, now)
+		if err != nil {
+			return err
+		}
+
+		found, err = getProduct(tx, res.ProductId, reserved, &stored)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return productModel.ErrProductUnknown
+		}
+
+		if stored.Quantity < res.Quantity {
+			return productModel.ErrNotEnoughItems
+		}
+
+		stored.Quantity -= res.Quantity
+		stored.Available = stored.Quantity - reserved[res.ProductId]
+
+		return putProduct(tx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, productModel.ErrReservationExpired
+	}
+	return &stored, nil
+}
+
+func (r *productRepository) ReleaseReservation(id productModel.ReservationId) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		reservations := tx.Bucket(reservationsBucket)
+		if reservations.Get([]byte(id)) == nil {
+			return productModel.ErrReservationUnknown
+		}
+		return reservations.Delete([]byte(id))
+	})
+}
+
+func (r *productRepository) ExpireReservations(now time.Time) ([]*productModel.Reservation, error) {
+	expired := []*productModel.Reservation{}
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		reservations := tx.Bucket(reservationsBucket)
+		err := reservations.ForEach(func(k, v []byte) error {
+			var res productModel.Reservation
+			if err := json.Unmarshal(v, &res); err != nil {
+				return err
+			}
+			if res.Expired(now) {
+				expired = append(expired, &res)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// keys must not be deleted while iterating over the bucket
+		for _, res := range expired {
+			if err := reservations.Delete([]byte(res.Id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func (r *productRepository) Find(id productModel.ProductId) (*productModel.Product, error) {
+	var p productModel.Product
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		found, err := getProduct(tx, id, reserved, &p)
+		if err == nil && !found {
+			return productModel.ErrProductUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *productRepository) FindAll() []*productModel.Product {
+	p := []*productModel.Product{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			var val productModel.Product
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			val.Available = val.Quantity - reserved[val.Id]
+			p = append(p, &val)
+			return nil
+		})
+	})
+	return p
+}
+
+func (r *productRepository) Search(q productModel.Query) (*productModel.Page, error) {
+	matching := []*productModel.Product{}
+	facets := q.NewFacetCounter()
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		reserved, err := reservedQuantities(tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// only the matching products are decoded into the result, the rest is dropped right away
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			var val productModel.Product
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			val.Available = val.Quantity - reserved[val.Id]
+			facets.Count(&val)
+			if q.Matches(&val) {
+				matching = append(matching, &val)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := q.Paginate(matching)
+	if err != nil {
+		return nil, err
+	}
+	page.Facets = facets.Facets()
+	return page, nil
+}
+
+func NewProductRepository(db *bbolt.DB) productModel.Repository {
+	return &productRepository{
+		db: db,
+	}
+}
+
+/* ---------- CATEGORY REPOSITORY ---------- */
+type categoryRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *categoryRepository) Add(c *categoryModel.Category) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(categoriesBucket).Get([]byte(c.Id)) != nil {
+			return categoryModel.ErrExists
+		}
+		return put(tx, categoriesBucket, c.Id.String(), c)
+	})
+}
+
+func (r *categoryRepository) Find(id categoryModel.CategoryId) (*categoryModel.Category, error) {
+	var c categoryModel.Category
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, categoriesBucket, id.String(), &c)
+		if err == nil && !found {
+			return categoryModel.ErrUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *categoryRepository) FindAll() []*categoryModel.Category {
+	c := []*categoryModel.Category{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(categoriesBucket).ForEach(func(k, v []byte) error {
+			var val categoryModel.Category
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			c = append(c, &val)
+			return nil
+		})
+	})
+	return c
+}
+
+func (r *categoryRepository) Remove(id categoryModel.CategoryId) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		categories := tx.Bucket(categoriesBucket)
+		if categories.Get([]byte(id)) == nil {
+			return categoryModel.ErrUnknown
+		}
+		return categories.Delete([]byte(id))
+	})
+}
+
+func NewCategoryRepository(db *bbolt.DB) categoryModel.Repository {
+	return &categoryRepository{
+		db: db,
+	}
+}
+
+/* ---------- CART REPOSITORY ---------- */
+type cartRepository struct {
+	db		*bbolt.DB
+}
+
+// runs fn on the user's cart and stores the result
+func (r *cartRepository) update(id userModel.UserId, fn func([]*productModel.SimpleProduct) []*productModel.SimpleProduct) ([]*productModel.SimpleProduct, error) {
+	userCart := []*productModel.SimpleProduct{}
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		if _, err := get(tx, cartsBucket, id.String(), &userCart); err != nil {
+			return err
+		}
+		userCart = fn(userCart)
+		return put(tx, cartsBucket, id.String(), userCart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userCart, nil
+}
+
+func (r *cartRepository) GetCart(id userModel.UserId) ([]*productModel.SimpleProduct, error) {
+	userCart := []*productModel.SimpleProduct{}
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		_, err := get(tx, cartsBucket, id.String(), &userCart)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userCart, nil
+}
+
+func (r *cartRepository) Put(userId userModel.UserId, productId productModel.ProductId, quantity int) ([]*productModel.SimpleProduct, error) {
+	return r.update(userId, func(userCart []*productModel.SimpleProduct) []*productModel.SimpleProduct {
+		for _, val := range userCart {
+			if val.Id == productId {
+				// item does already exist - we have to update the properties
+				val.Quantity = quantity
+				return userCart
+			}
+		}
+		// item does not exist yet - we have to append it to the array
+		return append(userCart, productModel.NewSimpleProduct(productId, quantity))
+	})
+}
+
+func (r *cartRepository) Remove(userId userModel.UserId, productId productModel.ProductId) ([]*productModel.SimpleProduct, error) {
+	return r.update(userId, func(userCart []*productModel.SimpleProduct) []*productModel.SimpleProduct {
+		for idx, val := range userCart {
+			if val.Id == productId {
+				return append(userCart[:idx], userCart[idx + 1:]...)
+			}
+		}
+		// in case the item was not found we just don't do anything
+		return userCart
+	})
+}
+
+func (r *cartRepository) Clear(userId userModel.UserId) ([]*productModel.SimpleProduct, error) {
+	return r.update(userId, func(userCart []*productModel.SimpleProduct) []*productModel.SimpleProduct {
+		return []*productModel.SimpleProduct{}
+	})
+}
+
+func NewCartRepository(db *bbolt.DB) cartModel.Repository {
+	return &cartRepository{
+		db: db,
+	}
+}
+
+/* ---------- ORDER REPOSITORY ---------- */
+type orderRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *orderRepository) Create(o *orderModel.Order) (order *orderModel.Order, err error) {
+	err = r.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, ordersBucket, o.Id.String(), o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (r *orderRepository) UpdateStatus(id orderModel.OrderId, change *orderModel.StatusChange) (order *orderModel.Order, err error) {
+	var o orderModel.Order
+	err = r.db.Update(func(tx *bbolt.Tx) error {
+		found, err := get(tx, ordersBucket, id.String(), &o)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return orderModel.ErrUnknown
+		}
+		if err := o.ChangeStatus(change); err != nil {
+			return err
+		}
+		return put(tx, ordersBucket, id.String(), &o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *orderRepository) Find(id orderModel.OrderId) (*orderModel.Order, error) {
+	var o orderModel.Order
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, ordersBucket, id.String(), &o)
+		if err == nil && !found {
+			return orderModel.ErrUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// returns all orders for which the filter returns true
+func (r *orderRepository) findWhere(filter func(*orderModel.Order) bool) []*orderModel.Order {
+	o := []*orderModel.Order{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(ordersBucket).ForEach(func(k, v []byte) error {
+			var val orderModel.Order
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			if filter(&val) {
+				o = append(o, &val)
+			}
+			return nil
+		})
+	})
+	return o
+}
+
+func (r *orderRepository) FindAll() []*orderModel.Order {
+	return r.findWhere(func(o *orderModel.Order) bool {
+		return true
+	})
+}
+
+func (r *orderRepository) FindAllForUser(userId userModel.UserId) []*orderModel.Order {
+	return r.findWhere(func(o *orderModel.Order) bool {
+		return o.UserId == userId
+	})
+}
+
+func (r *orderRepository) Search(query orderModel.Query) (*orderModel.Page, error) {
+	return query.Paginate(r.findWhere(query.Matches))
+}
+
+func NewOrderRepository(db *bbolt.DB) orderModel.Repository {
+	return &orderRepository{
+		db: db,
+	}
+}
+
+/* ---------- RETURN REPOSITORY ---------- */
+type returnRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *returnRepository) Create(ret *returnModel.Return) (*returnModel.Return, error) {
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, returnsBucket, ret.Id.String(), ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *returnRepository) UpdateStatus(id returnModel.ReturnId, change *returnModel.StatusChange) (*returnModel.Return, error) {
+	var ret returnModel.Return
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		found, err := get(tx, returnsBucket, id.String(), &ret)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return returnModel.ErrUnknown
+		}
+		if err := ret.ChangeStatus(change); err != nil {
+			return err
+		}
+		return put(tx, returnsBucket, id.String(), &ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (r *returnRepository) Find(id returnModel.ReturnId) (*returnModel.Return, error) {
+	var ret returnModel.Return
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, returnsBucket, id.String(), &ret)
+		if err == nil && !found {
+			return returnModel.ErrUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// returns all returns for which the filter returns true
+func (r *returnRepository) findWhere(filter func(*returnModel.Return) bool) []*returnModel.Return {
+	ret := []*returnModel.Return{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(returnsBucket).ForEach(func(k, v []byte) error {
+			var val returnModel.Return
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			if filter(&val) {
+				ret = append(ret, &val)
+			}
+			return nil
+		})
+	})
+	return ret
+}
+
+func (r *returnRepository) FindAll() []*returnModel.Return {
+	return r.findWhere(func(ret *returnModel.Return) bool {
+		return true
+	})
+}
+
+func (r *returnRepository) FindAllForOrder(orderId orderModel.OrderId) []*returnModel.Return {
+	return r.findWhere(func(ret *returnModel.Return) bool {
+		return ret.OrderId == orderId
+	})
+}
+
+func NewReturnRepository(db *bbolt.DB) returnModel.Repository {
+	return &returnRepository{
+		db: db,
+	}
+}
+
+/* ---------- PAYMENT REPOSITORY ---------- */
+type paymentRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *paymentRepository) Store(p *paymentModel.Payment) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, paymentsBucket, p.Id.String(), p)
+	})
+}
+
+func (r *paymentRepository) Find(id paymentModel.PaymentId) (*paymentModel.Payment, error) {
+	var p paymentModel.Payment
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, paymentsBucket, id.String(), &p)
+		if err == nil && !found {
+			return paymentModel.ErrUnknown
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *paymentRepository) findWhere(filter func(*paymentModel.Payment) bool) []*paymentModel.Payment {
+	p := []*paymentModel.Payment{}
+	r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(paymentsBucket).ForEach(func(k, v []byte) error {
+			var val paymentModel.Payment
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			if filter(&val) {
+				p = append(p, &val)
+			}
+			return nil
+		})
+	})
+	return p
+}
+
+func (r *paymentRepository) FindByReference(reference string) (*paymentModel.Payment, error) {
+	found := r.findWhere(func(p *paymentModel.Payment) bool {
+		return reference != "" && p.Reference == reference
+	})
+	if len(found) == 0 {
+		return nil, paymentModel.ErrUnknown
+	}
+	return found[0], nil
+}
+
+func (r *paymentRepository) FindAllForOrder(orderId orderModel.OrderId) []*paymentModel.Payment {
+	return r.findWhere(func(p *paymentModel.Payment) bool {
+		return p.OrderId == orderId
+	})
+}
+
+func NewPaymentRepository(db *bbolt.DB) paymentModel.Repository {
+	return &paymentRepository{
+		db: db,
+	}
+}
+
+/* ---------- TOKEN REPOSITORY ---------- */
+type tokenRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *tokenRepository) StoreRefreshToken(t *tokenModel.RefreshToken) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		refreshTokens := tx.Bucket(refreshTokensBucket)
+
+		// drop all refresh tokens that have expired without being used, so the store does not grow forever
+		expired := [][]byte{}
+		err := refreshTokens.ForEach(func(k, v []byte) error {
+			var val tokenModel.RefreshToken
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			if val.Expired() {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := refreshTokens.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return put(tx, refreshTokensBucket, t.Hash, t)
+	})
+}
+
+func (r *tokenRepository) TakeRefreshToken(hash string) (*tokenModel.RefreshToken, error) {
+	var t tokenModel.RefreshToken
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		found, err := get(tx, refreshTokensBucket, hash, &t)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return tokenModel.ErrUnknown
+		}
+		return tx.Bucket(refreshTokensBucket).Delete([]byte(hash))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		revoked := tx.Bucket(revokedTokensBucket)
+
+		// drop all entries of tokens that have expired in the meantime, so the revocation list does not grow forever
+		now := time.Now()
+		expired := [][]byte{}
+		err := revoked.ForEach(func(k, v []byte) error {
+			var val time.Time
+			if err := val.UnmarshalText(v); err != nil {
+				return err
+			}
+			if now.After(val) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := revoked.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		v, err := expiresAt.MarshalText()
+		if err != nil {
+			return err
+		}
+		return revoked.Put([]byte(jti), v)
+	})
+}
+
+func (r *tokenRepository) IsRevoked(jti string) bool {
+	revoked := false
+	r.db.View(func(tx *bbolt.Tx) error {
+		revoked = tx.Bucket(revokedTokensBucket).Get([]byte(jti)) != nil
+		return nil
+	})
+	return revoked
+}
+
+func NewTokenRepository(db *bbolt.DB) tokenModel.Repository {
+	return &tokenRepository{
+		db: db,
+	}
+}
+
+/* ---------- IDEMPOTENCY REPOSITORY ---------- */
+type idempotencyRepository struct {
+	db		*bbolt.DB
+}
+
+func (r *idempotencyRepository) Begin(record *idempotencyModel.Record, now time.Time) (*idempotencyModel.Record, error) {
+	var existing *idempotencyModel.Record
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		records := tx.Bucket(idempotencyBucket)
+
+		// keys must not be deleted while iterating over the bucket
+		expired := [][]byte{}
+		err := records.ForEach(func(k, v []byte) error {
+			var val idempotencyModel.Record
+			if err := json.Unmarshal(v, &val); err != nil {
+				return err
+			}
+			if val.Expired(now) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := records.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		var val idempotencyModel.Record
+		found, err := get(tx, idempotencyBucket, record.Key, &val)
+		if err != nil {
+			return err
+		}
+		if found {
+			existing = &val
+			return nil
+		}
+		return put(tx, idempotencyBucket, record.Key, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (r *idempotencyRepository) Complete(record *idempotencyModel.Record) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(idempotencyBucket).Get([]byte(record.Key)) == nil {
+			return idempotencyModel.ErrUnknown
+		}
+		return put(tx, idempotencyBucket, record.Key, record)
+	})
+}
+
+func (r *idempotencyRepository) Remove(key string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(idempotencyBucket).Delete([]byte(key))
+	})
+}
+
+func NewIdempotencyRepository(db *bbolt.DB) idempotencyModel.Repository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,27 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+	"go.etcd.io/bbolt"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/product/producttest"
+)
+
+func TestProductRepository(t *testing.T) {
+	producttest.TestRepository(t, func(t *testing.T) productModel.Repository {
+		return NewProductRepository(openTestDB(t))
+	})
+}
+
+// opens a new database with the sample data in a temporary directory, it is closed when the test has finished
+func openTestDB(t *testing.T) *bbolt.DB {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
\ No newline at end of file
@@ -5,10 +5,11 @@ import (
 	productModel "github.com/MICSTI/imsazon/models/product"
 	"github.com/go-kit/kit/endpoint"
 	"context"
+	"github.com/MICSTI/imsazon/auth"
 )
 
 type getCartRequest struct {
-	UserId			userModel.UserId
+
 }
 
 type getCartResponse struct {
@@ -21,14 +22,13 @@ func (r getCartResponse) error() error { return r.Err }
 
 func makeGetCartEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req := request.(getCartRequest)
-		cartItems, err := s.GetCart(req.UserId)
-		return getCartResponse{UserId: req.UserId, CartItems: cartItems, Err: err}, nil
+		userId := auth.UserIdFromContext(ctx)
+		cartItems, err := s.GetCart(userId)
+		return getCartResponse{UserId: userId, CartItems: cartItems, Err: err}, nil
 	}
 }
 
 type putItemRequest struct {
-	UserId			userModel.UserId
 	ProductId		productModel.ProductId
 	Quantity		int
 }
@@ -44,13 +44,13 @@ func (r putItemResponse) error() error { return r.Err }
 func makePutItemEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(putItemRequest)
-		updatedCart, err := s.Put(req.UserId, req.ProductId, req.Quantity)
-		return putItemResponse{UserId: req.UserId, CartItems: updatedCart, Err: err}, nil
+		userId := auth.UserIdFromContext(ctx)
+		updatedCart, err := s.Put(userId, req.ProductId, req.Quantity)
+		return putItemResponse{UserId: userId, CartItems: updatedCart, Err: err}, nil
 	}
 }
 
 type removeItemRequest struct {
-	UserId			userModel.UserId
 	ProductId		productModel.ProductId
 }
 
@@ -65,7 +65,8 @@ func (r removeItemResponse) error() error { return r.Err }
 func makeRemoveItemEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(removeItemRequest)
-		updatedCart, err := s.Remove(req.UserId, req.ProductId)
-		return removeItemResponse{UserId: req.UserId, CartItems: updatedCart, Err: err}, nil
+		userId := auth.UserIdFromContext(ctx)
+		updatedCart, err := s.Remove(userId, req.ProductId)
+		return removeItemResponse{UserId: userId, CartItems: updatedCart, Err: err}, nil
 	}
 }
\ No newline at end of file
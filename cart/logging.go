@@ -54,4 +54,16 @@ func (s *loggingService) Remove(userId userModel.UserId, productId productModel.
 		)
 	}(time.Now())
 	return s.Service.Remove(userId, productId)
+}
+
+func (s *loggingService) Clear(userId userModel.UserId) (updatedCart []*productModel.SimpleProduct, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Clear",
+			"userId", userId,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Clear(userId)
 }
\ No newline at end of file
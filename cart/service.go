@@ -24,6 +24,9 @@ type Service interface {
 
 	// Remove deletes an item from the user's cart
 	Remove(userId userModel.UserId, productId productModel.ProductId) ([]*productModel.SimpleProduct, error)
+
+	// Clear removes all items from the user's cart
+	Clear(userId userModel.UserId) ([]*productModel.SimpleProduct, error)
 }
 
 type service struct {
@@ -54,6 +57,14 @@ func (s *service) Remove(userId userModel.UserId, productId productModel.Product
 	return s.carts.Remove(userId, productId)
 }
 
+func (s *service) Clear(userId userModel.UserId) (updatedCart []*productModel.SimpleProduct, err error) {
+	if userId == "" {
+		return []*productModel.SimpleProduct{}, ErrInvalidArgument
+	}
+
+	return s.carts.Clear(userId)
+}
+
 // NewService creates a cart service with the necessary dependencies
 func NewService(carts cartModel.Repository) Service {
 	return &service{
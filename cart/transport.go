@@ -6,34 +6,37 @@ import (
 	"encoding/json"
 	"context"
 	"net/http"
-	userModel "github.com/MICSTI/imsazon/models/user"
 	productModel "github.com/MICSTI/imsazon/models/product"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
 )
 
 // MakeHandler returns a handler for the cart service
-func MakeHandler(cs Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(cs Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+
 	getCartHandler := kithttp.NewServer(
-		makeGetCartEndpoint(cs),
+		authenticate(makeGetCartEndpoint(cs)),
 		decodeGetCartRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	putItemHandler := kithttp.NewServer(
-		makePutItemEndpoint(cs),
+		authenticate(makePutItemEndpoint(cs)),
 		decodePutItemRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	removeItemHandler := kithttp.NewServer(
-		makeRemoveItemEndpoint(cs),
+		authenticate(makeRemoveItemEndpoint(cs)),
 		decodeRemoveItemRequest,
 		encodeResponse,
 		opts...,
@@ -49,22 +52,12 @@ func MakeHandler(cs Service, logger kitlog.Logger) http.Handler {
 }
 
 func decodeGetCartRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var body struct {
-		UserId			userModel.UserId			`json:"userId"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		return nil, err
-	}
-
-	return getCartRequest{
-		UserId:			body.UserId,
-	}, nil
+	// the cart always belongs to the authenticated user, so there is nothing to decode here
+	return getCartRequest{}, nil
 }
 
 func decodePutItemRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		UserId			userModel.UserId		`json:"userId"`
 		ProductId		productModel.ProductId	`json:"productId"`
 		Quantity		int						`json:"quantity"`
 	}
@@ -74,7 +67,6 @@ func decodePutItemRequest(_ context.Context, r *http.Request) (interface{}, erro
 	}
 
 	return putItemRequest{
-		UserId:			body.UserId,
 		ProductId:		body.ProductId,
 		Quantity:		body.Quantity,
 	}, nil
@@ -82,7 +74,6 @@ func decodePutItemRequest(_ context.Context, r *http.Request) (interface{}, erro
 
 func decodeRemoveItemRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		UserId			userModel.UserId			`json:"userId"`
 		ProductId		productModel.ProductId		`json:"productId"`
 	}
 
@@ -91,7 +82,6 @@ func decodeRemoveItemRequest(_ context.Context, r *http.Request) (interface{}, e
 	}
 
 	return removeItemRequest{
-		UserId:			body.UserId,
 		ProductId:		body.ProductId,
 	}, nil
 }
@@ -116,6 +106,14 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	switch err {
 	case ErrInvalidArgument:
 		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -0,0 +1,139 @@
+package catalog
+
+import (
+	"context"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	"github.com/go-kit/kit/endpoint"
+)
+
+type createRequest struct {
+	Product		productModel.Product
+}
+
+type createResponse struct {
+	Product		*productModel.Product	`json:"product,omitempty"`
+	Err			error					`json:"error,omitempty"`
+}
+
+func (r createResponse) error() error { return r.Err }
+
+func makeCreateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(createRequest)
+		product, err := s.Create(&req.Product)
+		return createResponse{Product: product, Err: err}, nil
+	}
+}
+
+type updateRequest struct {
+	Product		productModel.Product
+}
+
+type updateResponse struct {
+	Product		*productModel.Product	`json:"product,omitempty"`
+	Err			error					`json:"error,omitempty"`
+}
+
+func (r updateResponse) error() error { return r.Err }
+
+func makeUpdateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateRequest)
+		product, err := s.Update(&req.Product)
+		return updateResponse{Product: product, Err: err}, nil
+	}
+}
+
+type deleteRequest struct {
+	Id			productModel.ProductId
+}
+
+type deleteResponse struct {
+	Err			error		`json:"error,omitempty"`
+}
+
+func (r deleteResponse) error() error { return r.Err }
+
+func makeDeleteEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(deleteRequest)
+		err := s.Delete(req.Id)
+		return deleteResponse{Err: err}, nil
+	}
+}
+
+type getRequest struct {
+	Id			productModel.ProductId
+}
+
+type getResponse struct {
+	Product		*productModel.Product	`json:"product,omitempty"`
+	Err			error					`json:"error,omitempty"`
+}
+
+func (r getResponse) error() error { return r.Err }
+
+func makeGetEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getRequest)
+		product, err := s.Get(req.Id)
+		return getResponse{Product: product, Err: err}, nil
+	}
+}
+
+type getCategoriesRequest struct {
+
+}
+
+type getCategoriesResponse struct {
+	Categories		[]*categoryModel.Node		`json:"categories"`
+	Err				error						`json:"error,omitempty"`
+}
+
+func (r getCategoriesResponse) error() error { return r.Err }
+
+func makeGetCategoriesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		categories := s.GetCategories()
+		return getCategoriesResponse{Categories: categories, Err: nil}, nil
+	}
+}
+
+type createCategoryRequest struct {
+	Name			string
+	ParentId		categoryModel.CategoryId
+}
+
+type createCategoryResponse struct {
+	Category		*categoryModel.Category		`json:"category,omitempty"`
+	Err				error						`json:"error,omitempty"`
+}
+
+func (r createCategoryResponse) error() error { return r.Err }
+
+func makeCreateCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(createCategoryRequest)
+		category, err := s.CreateCategory(req.Name, req.ParentId)
+		return createCategoryResponse{Category: category, Err: err}, nil
+	}
+}
+
+type deleteCategoryRequest struct {
+	Id				categoryModel.CategoryId
+}
+
+type deleteCategoryResponse struct {
+	Err				error		`json:"error,omitempty"`
+}
+
+func (r deleteCategoryResponse) error() error { return r.Err }
+
+func makeDeleteCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(deleteCategoryRequest)
+		err := s.DeleteCategory(req.Id)
+		return deleteCategoryResponse{Err: err}, nil
+	}
+}
\ No newline at end of file
@@ -0,0 +1,67 @@
+package catalog
+
+import (
+	"github.com/go-kit/kit/log"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	"time"
+)
+
+type loggingService struct {
+	logger log.Logger
+	Service
+}
+
+// NewLoggingService returns a new instance of a logging service
+func NewLoggingService(logger log.Logger, s Service) Service {
+	return &loggingService{logger, s}
+}
+
+func (s *loggingService) Create(product *productModel.Product) (created *productModel.Product, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Create", "product_id", created.Id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Create(product)
+}
+
+func (s *loggingService) Update(product *productModel.Product) (updated *productModel.Product, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Update", "product_id", product.Id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Update(product)
+}
+
+func (s *loggingService) Delete(id productModel.ProductId) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Delete", "product_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Delete(id)
+}
+
+func (s *loggingService) Get(id productModel.ProductId) (product *productModel.Product, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Get", "product_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Get(id)
+}
+
+func (s *loggingService) GetCategories() (categories []*categoryModel.Node) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "GetCategories", "took", time.Since(begin), "err", nil)
+	}(time.Now())
+	return s.Service.GetCategories()
+}
+
+func (s *loggingService) CreateCategory(name string, parentId categoryModel.CategoryId) (category *categoryModel.Category, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "CreateCategory", "category_id", category.Id, "parent_id", parentId, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.CreateCategory(name, parentId)
+}
+
+func (s *loggingService) DeleteCategory(id categoryModel.CategoryId) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "DeleteCategory", "category_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.DeleteCategory(id)
+}
\ No newline at end of file
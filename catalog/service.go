@@ -0,0 +1,245 @@
+/*
+	The catalog service manages the product data of IMSazon: name, description, category, image and price.
+	Products are created, updated and deleted here, their quantities are managed by the stock service.
+	It also manages the category tree the products are sorted into.
+ */
+package catalog
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+)
+
+// ErrInvalidArgument is returned when one or more arguments are invalid
+var ErrInvalidArgument = errors.New("Invalid argument")
+
+// ErrInvalidName is returned when the product name is empty or too long
+var ErrInvalidName = errors.New("The product name must not be empty or longer than 200 characters")
+
+// ErrInvalidDescription is returned when the product description is too long
+var ErrInvalidDescription = errors.New("The product description must not be longer than 5000 characters")
+
+// ErrInvalidCategory is returned when the product category does not exist
+var ErrInvalidCategory = errors.New("The product category must be an existing category")
+
+// ErrInvalidCategoryName is returned when the category name is empty or too long
+var ErrInvalidCategoryName = errors.New("The category name must not be empty or longer than 100 characters")
+
+// ErrInvalidParentCategory is returned when the parent of a new category does not exist
+var ErrInvalidParentCategory = errors.New("The parent category does not exist")
+
+// ErrCategoryInUse is returned when a category that still has sub categories or products is deleted
+var ErrCategoryInUse = errors.New("The category still contains sub categories or products")
+
+// ErrInvalidImageUrl is returned when the image url is not an absolute http(s) url
+var ErrInvalidImageUrl = errors.New("The image url must be an absolute http or https url")
+
+// ErrInvalidPrice is returned when the price is not a positive amount in a supported currency
+var ErrInvalidPrice = errors.New("The price must be a positive amount in a supported currency")
+
+const (
+	maxNameLength = 200
+	maxDescriptionLength = 5000
+	maxCategoryNameLength = 100
+)
+
+type Service interface {
+	// Create adds a new product to the catalog. The product id is generated, the stock quantity starts at 0.
+	Create(product *productModel.Product) (*productModel.Product, error)
+
+	// Update replaces the catalog data of an existing product, the stock quantity is not changed
+	Update(product *productModel.Product) (*productModel.Product, error)
+
+	// Delete removes a product from the catalog and the stock
+	Delete(id productModel.ProductId) error
+
+	// Get returns a single product
+	Get(id productModel.ProductId) (*productModel.Product, error)
+
+	// GetCategories returns all categories arranged as a tree
+	GetCategories() []*categoryModel.Node
+
+	// CreateCategory adds a new category below the parent category, or at the top of the tree if the parent id is empty
+	CreateCategory(name string, parentId categoryModel.CategoryId) (*categoryModel.Category, error)
+
+	// DeleteCategory removes a category that contains neither sub categories nor products
+	DeleteCategory(id categoryModel.CategoryId) error
+}
+
+type service struct {
+	products		productModel.Repository
+	categories		categoryModel.Repository
+}
+
+func (s *service) Create(product *productModel.Product) (*productModel.Product, error) {
+	p := normalize(product)
+
+	if err := s.validate(p); err != nil {
+		return &productModel.Product{}, err
+	}
+
+	p.Id = productModel.NextProductId()
+
+	created, err := s.products.Store(p)
+
+	if err != nil {
+		return &productModel.Product{}, err
+	}
+
+	return created, nil
+}
+
+func (s *service) Update(product *productModel.Product) (*productModel.Product, error) {
+	if product.Id == "" {
+		return &productModel.Product{}, ErrInvalidArgument
+	}
+
+	p := normalize(product)
+
+	if err := s.validate(p); err != nil {
+		return &productModel.Product{}, err
+	}
+
+	updated, err := s.products.Update(p)
+
+	if err != nil {
+		return &productModel.Product{}, err
+	}
+
+	return updated, nil
+}
+
+func (s *service) Delete(id productModel.ProductId) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	return s.products.Remove(id)
+}
+
+func (s *service) Get(id productModel.ProductId) (*productModel.Product, error) {
+	if id == "" {
+		return &productModel.Product{}, ErrInvalidArgument
+	}
+
+	p, err := s.products.Find(id)
+
+	if err != nil {
+		return &productModel.Product{}, err
+	}
+
+	return p, nil
+}
+
+// returns a copy of the catalog data with surrounding whitespace removed
+// stock quantities are never taken over from catalog requests
+func normalize(product *productModel.Product) *productModel.Product {
+	return productModel.New(
+		product.Id,
+		strings.TrimSpace(product.Name),
+		strings.TrimSpace(product.Description),
+		strings.TrimSpace(product.Category),
+		strings.TrimSpace(product.ImageUrl),
+		product.Price,
+		0,
+	)
+}
+
+func (s *service) GetCategories() []*categoryModel.Node {
+	return categoryModel.BuildTree(s.categories.FindAll())
+}
+
+func (s *service) CreateCategory(name string, parentId categoryModel.CategoryId) (*categoryModel.Category, error) {
+	name = strings.TrimSpace(name)
+	id := categoryModel.IdFromName(name)
+
+	if id == "" || utf8.RuneCountInString(name) > maxCategoryNameLength {
+		return &categoryModel.Category{}, ErrInvalidCategoryName
+	}
+
+	if parentId != "" {
+		if _, err := s.categories.Find(parentId); err != nil {
+			return &categoryModel.Category{}, ErrInvalidParentCategory
+		}
+	}
+
+	c := categoryModel.New(id, name, parentId)
+
+	if err := s.categories.Add(c); err != nil {
+		return &categoryModel.Category{}, err
+	}
+
+	return c, nil
+}
+
+func (s *service) DeleteCategory(id categoryModel.CategoryId) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	if _, err := s.categories.Find(id); err != nil {
+		return err
+	}
+
+	for _, c := range s.categories.FindAll() {
+		if c.ParentId == id {
+			return ErrCategoryInUse
+		}
+	}
+
+	page, err := s.products.Search(productModel.Query{Categories: []string{id.String()}, Limit: 1})
+
+	if err != nil {
+		return err
+	}
+
+	if page.Total > 0 {
+		return ErrCategoryInUse
+	}
+
+	return s.categories.Remove(id)
+}
+
+// checks the catalog data of a product
+func (s *service) validate(p *productModel.Product) error {
+	if p.Name == "" || utf8.RuneCountInString(p.Name) > maxNameLength {
+		return ErrInvalidName
+	}
+
+	if utf8.RuneCountInString(p.Description) > maxDescriptionLength {
+		return ErrInvalidDescription
+	}
+
+	if p.Category == "" {
+		return ErrInvalidCategory
+	}
+
+	if _, err := s.categories.Find(categoryModel.CategoryId(p.Category)); err != nil {
+		return ErrInvalidCategory
+	}
+
+	if p.ImageUrl != "" {
+		u, err := url.ParseRequestURI(p.ImageUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidImageUrl
+		}
+	}
+
+	if !p.Price.Currency.Valid() || !p.Price.IsPositive() {
+		return ErrInvalidPrice
+	}
+
+	return nil
+}
+
+// NewService creates a catalog service with the necessary dependencies
+func NewService(products productModel.Repository, categories categoryModel.Repository) Service {
+	return &service{
+		products: products,
+		categories: categories,
+	}
+}
\ No newline at end of file
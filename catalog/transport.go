@@ -0,0 +1,258 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"github.com/MICSTI/imsazon/auth"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	"github.com/MICSTI/imsazon/models/money"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	kitlog "github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// ErrBadRoute is returned when a route parameter is missing
+var ErrBadRoute = errors.New("Bad route")
+
+// MakeHandler returns a handler for the catalog service
+func MakeHandler(cs Service, as auth.Service, logger kitlog.Logger) http.Handler {
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
+	}
+
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin)
+
+	createHandler := kithttp.NewServer(
+		authenticate(authorize(makeCreateEndpoint(cs))),
+		decodeCreateRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	updateHandler := kithttp.NewServer(
+		authenticate(authorize(makeUpdateEndpoint(cs))),
+		decodeUpdateRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	deleteHandler := kithttp.NewServer(
+		authenticate(authorize(makeDeleteEndpoint(cs))),
+		decodeDeleteRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getHandler := kithttp.NewServer(
+		authenticate(makeGetEndpoint(cs)),
+		decodeGetRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getCategoriesHandler := kithttp.NewServer(
+		authenticate(makeGetCategoriesEndpoint(cs)),
+		decodeGetCategoriesRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	createCategoryHandler := kithttp.NewServer(
+		authenticate(authorize(makeCreateCategoryEndpoint(cs))),
+		decodeCreateCategoryRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	deleteCategoryHandler := kithttp.NewServer(
+		authenticate(authorize(makeDeleteCategoryEndpoint(cs))),
+		decodeDeleteCategoryRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/catalog/products", createHandler).Methods("POST")
+	r.Handle("/catalog/products/{productId}", getHandler).Methods("GET")
+	r.Handle("/catalog/products/{productId}", updateHandler).Methods("POST")
+	r.Handle("/catalog/products/{productId}", deleteHandler).Methods("DELETE")
+	r.Handle("/catalog/categories", getCategoriesHandler).Methods("GET")
+	r.Handle("/catalog/categories", createCategoryHandler).Methods("POST")
+	r.Handle("/catalog/categories/{categoryId}", deleteCategoryHandler).Methods("DELETE")
+
+	return r
+}
+
+func decodeCreateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Product		productModel.Product		`json:"product"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return createRequest{
+		Product:	body.Product,
+	}, nil
+}
+
+func decodeUpdateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["productId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	var body struct {
+		Product		productModel.Product		`json:"product"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	// the product id is always taken from the route
+	body.Product.Id = productModel.ProductId(id)
+
+	return updateRequest{
+		Product:	body.Product,
+	}, nil
+}
+
+func decodeDeleteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["productId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return deleteRequest{
+		Id:		productModel.ProductId(id),
+	}, nil
+}
+
+func decodeGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["productId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return getRequest{
+		Id:		productModel.ProductId(id),
+	}, nil
+}
+
+func decodeGetCategoriesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	// there are no parameters to the request, so we don't need to decode anything
+	return getCategoriesRequest{}, nil
+}
+
+func decodeCreateCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Name		string						`json:"name"`
+		ParentId	categoryModel.CategoryId	`json:"parentId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return createCategoryRequest{
+		Name:		body.Name,
+		ParentId:	body.ParentId,
+	}, nil
+}
+
+func decodeDeleteCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["categoryId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return deleteCategoryRequest{
+		Id:		categoryModel.CategoryId(id),
+	}, nil
+}
+
+// encode the JSON response
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(erroer); ok && e.error() != nil {
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+type erroer interface {
+	error() error
+}
+
+// encode errors from business logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	case ErrInvalidArgument:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidName:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidDescription:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidCategory:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidCategoryName:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidParentCategory:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrCategoryInUse:
+		w.WriteHeader(http.StatusConflict)
+	case categoryModel.ErrExists:
+		w.WriteHeader(http.StatusConflict)
+	case categoryModel.ErrUnknown:
+		w.WriteHeader(http.StatusNotFound)
+	case money.ErrInvalidAmount:
+		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrUnknownCurrency:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidImageUrl:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidPrice:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrBadRoute:
+		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrProductUnknown:
+		w.WriteHeader(http.StatusNotFound)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
\ No newline at end of file
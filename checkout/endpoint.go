@@ -0,0 +1,27 @@
+package checkout
+
+import (
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	"github.com/go-kit/kit/endpoint"
+	"context"
+	"github.com/MICSTI/imsazon/auth"
+)
+
+type checkoutRequest struct {
+	PaymentDetails		PaymentDetails
+}
+
+type checkoutResponse struct {
+	Order				*orderModel.Order		`json:"order,omitempty"`
+	Err					error					`json:"error,omitempty"`
+}
+
+func (r checkoutResponse) error() error { return r.Err }
+
+func makeCheckoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(checkoutRequest)
+		o, err := s.Checkout(auth.UserIdFromContext(ctx), req.PaymentDetails)
+		return checkoutResponse{Order: o, Err: err}, nil
+	}
+}
\ No newline at end of file
@@ -0,0 +1,35 @@
+package checkout
+
+import (
+	"github.com/go-kit/kit/log"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	"time"
+)
+
+type loggingService struct {
+	logger log.Logger
+	Service
+}
+
+// NewLoggingService returns a new instance of a logging service
+func NewLoggingService(logger log.Logger, s Service) Service {
+	return &loggingService{logger, s}
+}
+
+func (s *loggingService) Checkout(userId userModel.UserId, paymentDetails PaymentDetails) (order *orderModel.Order, err error) {
+	defer func(begin time.Time) {
+		var orderId orderModel.OrderId
+		if order != nil {
+			orderId = order.Id
+		}
+		s.logger.Log(
+			"method", "Checkout",
+			"userId", userId,
+			"orderId", orderId,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Checkout(userId, paymentDetails)
+}
\ No newline at end of file
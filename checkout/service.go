@@ -0,0 +1,178 @@
+/*
+	The checkout service turns the shopping cart of a user into a paid order.
+	It runs the necessary steps (creating the order, reserving the items in the stock, authorizing the payment)
+	as a saga - if one of the steps fails, the previous ones are compensated:
+	the reservations are released, the order is marked as "Payment Error" and the cart is kept,
+	so the user can simply try again. Only after a successful payment the reserved items are withdrawn and the cart is cleared.
+ */
+package checkout
+
+import (
+	"errors"
+	"time"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	"github.com/MICSTI/imsazon/models/money"
+	"github.com/MICSTI/imsazon/cart"
+	"github.com/MICSTI/imsazon/order"
+	"github.com/MICSTI/imsazon/stock"
+	"github.com/MICSTI/imsazon/payment"
+)
+
+// ErrInvalidArgument is returned when one or more arguments are invalid.
+var ErrInvalidArgument = errors.New("Invalid argument")
+
+// ErrEmptyCart is returned when a user tries to check out an empty cart
+var ErrEmptyCart = errors.New("The cart is empty")
+
+// the items are held in the stock for this long while the payment is processed
+const reservationTTL = time.Minute * 10
+
+// PaymentDetails contains the credit card information used to pay for the order
+// the currency is optional - if it is set, it has to match the currency of the product prices
+type PaymentDetails struct {
+	CardNumber			string
+	Currency			string
+}
+
+// Service is the interface that provides the checkout method
+type Service interface {
+	// Checkout creates an order out of the user's cart, withdraws the items from the stock and charges the credit card
+	Checkout(userId userModel.UserId, paymentDetails PaymentDetails) (*orderModel.Order, error)
+}
+
+type service struct {
+	carts			cart.Service
+	orders			order.Service
+	stock			stock.Service
+	payments		payment.Service
+}
+
+func (s *service) Checkout(userId userModel.UserId, paymentDetails PaymentDetails) (*orderModel.Order, error) {
+	if userId == "" || paymentDetails.CardNumber == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	cartItems, err := s.carts.GetCart(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cartItems) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	// copy the cart items so later changes to the cart do not affect the order
+	items := make([]*productModel.SimpleProduct, 0, len(cartItems))
+	for _, item := range cartItems {
+		items = append(items, productModel.NewSimpleProduct(item.Id, item.Quantity))
+	}
+
+	// the order service takes a snapshot of the prices and calculates the total
+	createdOrder, err := s.orders.Create(orderModel.New("", userId, items))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if paymentDetails.Currency != "" && paymentDetails.Currency != createdOrder.Total.Currency.String() {
+		s.compensate(createdOrder.Id, nil, userId)
+		return nil, money.ErrCurrencyMismatch
+	}
+
+	// reserve all items, so nobody else can buy them while the payment is processed
+	reservations := []productModel.ReservationId{}
+	for _, item := range items {
+		reservation, err := s.stock.Reserve(item.Id, item.Quantity, reservationTTL)
+
+		if err != nil {
+			s.compensate(createdOrder.Id, reservations, userId)
+			return nil, err
+		}
+
+		reservations = append(reservations, reservation.Id)
+	}
+
+	// the amount is only authorized, it is captured when the order ships
+	authorization, err := s.payments.Authorize(payment.CreditCardCharge{
+		Id:				createdOrder.Id.String(),
+		CardNumber:		paymentDetails.CardNumber,
+		Amount:			createdOrder.Total,
+	})
+
+	if err != nil {
+		s.compensate(createdOrder.Id, reservations, userId)
+		return nil, err
+	}
+
+	// a reservation can expire while the payment is slow, its items are withdrawn directly then
+	// if they have been sold in the meantime, the authorization is released and the checkout fails
+	// there is one reservation per item, in the same order
+	withdrawn := []*productModel.SimpleProduct{}
+	for i, id := range reservations {
+		if _, err := s.stock.Commit(id); err != nil {
+			if _, err := s.stock.Withdraw(items[i]); err != nil {
+				s.payments.Void(authorization.Id)
+				s.restock(withdrawn)
+				s.compensate(createdOrder.Id, reservations[i + 1:], userId)
+				return nil, err
+			}
+		}
+
+		withdrawn = append(withdrawn, items[i])
+	}
+
+	// only the checkout marks an authorized order as paid, the payment webhook leaves it alone
+	paidOrder, err := s.orders.UpdateStatus(createdOrder.Id, orderModel.PaymentSuccessful, userId.String())
+
+	if err == orderModel.ErrInvalidOperation {
+		// the order has been cancelled while the payment was processed, so the items are given back and the authorization is released
+		s.restock(withdrawn)
+		s.payments.Void(authorization.Id)
+		return nil, err
+	}
+
+	if err != nil {
+		// the order could not be marked as paid, so it is rolled back like a failed payment
+		s.payments.Void(authorization.Id)
+		s.restock(withdrawn)
+		s.compensate(createdOrder.Id, nil, userId)
+		return nil, err
+	}
+
+	// the order has already been paid at this point, so a cart that could not be cleared must not fail the checkout
+	s.carts.Clear(userId)
+
+	return paidOrder, nil
+}
+
+// releases the reserved items and marks the order as "Payment Error"
+// the compensation is best effort - there is nothing left to roll back if one of these calls fails as well
+// reservations that could not be released expire on their own
+// the user who checks out is recorded as actor of the status change
+func (s *service) compensate(orderId orderModel.OrderId, reservations []productModel.ReservationId, userId userModel.UserId) {
+	for _, id := range reservations {
+		s.stock.Release(id)
+	}
+
+	s.orders.UpdateStatus(orderId, orderModel.PaymentError, userId.String())
+}
+
+// puts items that have already been withdrawn back into the stock, this is best effort as well
+func (s *service) restock(items []*productModel.SimpleProduct) {
+	for _, item := range items {
+		s.stock.Add(item)
+	}
+}
+
+// NewService creates a checkout service with the necessary dependencies
+func NewService(carts cart.Service, orders order.Service, stock stock.Service, payments payment.Service) Service {
+	return &service{
+		carts:			carts,
+		orders:			orders,
+		stock:			stock,
+		payments:		payments,
+	}
+}
\ No newline at end of file
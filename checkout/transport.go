@@ -0,0 +1,118 @@
+package checkout
+
+import (
+	kitlog "github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"encoding/json"
+	"context"
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/money"
+	"github.com/MICSTI/imsazon/order"
+	"github.com/MICSTI/imsazon/payment"
+)
+
+// MakeHandler returns a handler for the checkout service
+func MakeHandler(cos Service, as auth.Service, logger kitlog.Logger) http.Handler {
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
+	}
+
+	authenticate := auth.NewAuthenticationMiddleware(as)
+
+	checkoutHandler := kithttp.NewServer(
+		authenticate(makeCheckoutEndpoint(cos)),
+		decodeCheckoutRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/checkout", checkoutHandler).Methods("POST")
+
+	return r
+}
+
+func decodeCheckoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		CardNumber		string					`json:"creditCard"`
+		Currency		string					`json:"currency"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return checkoutRequest{
+		PaymentDetails:	PaymentDetails{
+			CardNumber:		body.CardNumber,
+			Currency:		body.Currency,
+		},
+	}, nil
+}
+
+// encode the JSON response
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(erroer); ok && e.error() != nil {
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+type erroer interface {
+	error() error
+}
+
+// encode errors from business logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// cart items that can't be ordered are reported line by line
+	if e, ok := err.(*order.ValidationError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+			"lines": e.Lines,
+		})
+		return
+	}
+	switch err {
+	case ErrInvalidArgument:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrEmptyCart:
+		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrProductUnknown:
+		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrNotEnoughItems:
+		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrCurrencyMismatch:
+		w.WriteHeader(http.StatusBadRequest)
+	case payment.ErrInvalidArgument:
+		w.WriteHeader(http.StatusBadRequest)
+	case payment.ErrCard:
+		w.WriteHeader(http.StatusBadRequest)
+	case payment.ErrValidation:
+		w.WriteHeader(http.StatusBadRequest)
+	case payment.ErrOther:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
\ No newline at end of file
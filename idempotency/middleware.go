@@ -0,0 +1,199 @@
+/*
+	The idempotency middleware makes it safe to retry requests that must not be executed twice, e.g. creating an order or charging a credit card.
+	A client sends a unique key in the "Idempotency-Key" header - the first request with the key is handled as usual and its response is stored.
+	Retries with the same key get the stored response instead of being executed again, as long as the key has not expired.
+	Keys are bound to the authenticated user and the route, and a retry has to send exactly the same body as the first request.
+	Responses with a server error are not stored, so a request that failed because of a temporary problem can be retried.
+ */
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/MICSTI/imsazon/auth"
+	idempotencyModel "github.com/MICSTI/imsazon/models/idempotency"
+)
+
+// HeaderName is the request header containing the idempotency key
+const HeaderName = "Idempotency-Key"
+
+// ReplayedHeaderName is set on responses that have been returned from the store
+const ReplayedHeaderName = "Idempotent-Replayed"
+
+// DefaultWindow is used when no window is configured
+const DefaultWindow = time.Hour * 24
+
+const maxKeyLength = 255
+
+// the body is kept in memory to fingerprint it and to pass it on, so larger bodies are rejected
+const maxBodySize = 1 << 20
+
+// ErrInvalidKey is returned when the idempotency key is longer than 255 characters
+var ErrInvalidKey = errors.New("Invalid idempotency key")
+
+// ErrBodyTooLarge is returned when the body of a request with an idempotency key is larger than 1 MB
+var ErrBodyTooLarge = errors.New("The request body is too large")
+
+// ErrKeyReused is returned when an idempotency key is used again for a request with a different body
+var ErrKeyReused = errors.New("The idempotency key has already been used for a different request")
+
+// ErrInProgress is returned when a request is retried while the first request with the same key is still being handled
+var ErrInProgress = errors.New("A request with this idempotency key is still being processed")
+
+// NewMiddleware returns a middleware that stores the responses of requests with an idempotency key for the duration of the window
+// requests without a key or without a valid JWT auth token are passed through unchanged
+func NewMiddleware(records idempotencyModel.Repository, as auth.Service, window time.Duration, logger kitlog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderName)
+
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxKeyLength {
+				encodeError(ErrInvalidKey, http.StatusBadRequest, w)
+				return
+			}
+
+			// the authentication itself is left to the handler, it rejects the request if the token is not valid
+			claims, err := as.Check(auth.TokenFromContext(auth.HTTPToContext()(r.Context(), r)))
+
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// one byte more than allowed is read to detect bodies that are too large
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+
+			if err != nil {
+				encodeError(err, http.StatusBadRequest, w)
+				return
+			}
+
+			if len(body) > maxBodySize {
+				encodeError(ErrBodyTooLarge, http.StatusRequestEntityTooLarge, w)
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			record := idempotencyModel.New(hash(claims.Subject, r.Method, r.URL.Path, key), hash(string(body)), now.Add(window))
+
+			existing, err := records.Begin(record, now)
+
+			if err != nil {
+				logger.Log("msg", "could not store idempotency key", "err", err)
+				encodeError(err, http.StatusInternalServerError, w)
+				return
+			}
+
+			if existing != nil {
+				replay(existing, record.Fingerprint, w)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w}
+			completed := false
+
+			// the key is released if the handler panics, so the request can be retried
+			defer func() {
+				if !completed {
+					records.Remove(record.Key)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.statusCode >= http.StatusInternalServerError {
+				return
+			}
+
+			record.Completed = true
+			record.StatusCode = recorder.status()
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.Body = recorder.body.Bytes()
+
+			if err := records.Complete(record); err != nil {
+				logger.Log("msg", "could not store idempotent response", "err", err)
+				return
+			}
+
+			completed = true
+		})
+	}
+}
+
+// writes the stored response of a previous request with the same key
+func replay(existing *idempotencyModel.Record, fingerprint string, w http.ResponseWriter) {
+	if existing.Fingerprint != fingerprint {
+		encodeError(ErrKeyReused, http.StatusConflict, w)
+		return
+	}
+
+	if !existing.Completed {
+		encodeError(ErrInProgress, http.StatusConflict, w)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set(ReplayedHeaderName, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Body)
+}
+
+// returns the hex encoded SHA-256 hash of the values, separated by line breaks
+func hash(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// passes the response through and keeps a copy of the status code and the body
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode		int
+	body			bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// handlers that don't set a status code answer with "200 OK"
+func (w *responseRecorder) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
+
+func encodeError(err error, statusCode int, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
\ No newline at end of file
@@ -6,23 +6,66 @@ package inmemory
 
 import (
 	"sync"
+	"time"
 	userModel "github.com/MICSTI/imsazon/models/user"
 	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
 	cartModel "github.com/MICSTI/imsazon/models/cart"
 	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	tokenModel "github.com/MICSTI/imsazon/models/token"
+	idempotencyModel "github.com/MICSTI/imsazon/models/idempotency"
 )
 
 /* ---------- USER REPOSITORY ---------- */
+// only used for comparing passwords of unknown usernames
+var dummyUser = &userModel.User{PasswordHash: "$2a$10$eEl3Vbc6q8qcz1Jo1qyO3.cLbwqwwsavgbZ2bldOkvkcBp99NWBou"}
+
 type userRepository struct {
-	mtx		sync.RWMutex
-	users	map[userModel.UserId]*userModel.User
+	mtx			sync.RWMutex
+	users		map[userModel.UserId]*userModel.User
+	usernames	map[string]userModel.UserId
 }
 
 // adds a user to the repository store
 func (r *userRepository) Add(u *userModel.User) error {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
+	for _, val := range r.users {
+		if val.Username == u.Username {
+			return userModel.ErrUsernameTaken
+		}
+		if val.Email == u.Email {
+			return userModel.ErrEmailTaken
+		}
+	}
+	r.users[u.Id] = u
+	r.usernames[u.Username] = u.Id
+	return nil
+}
+
+// replaces the stored data of an existing user
+func (r *userRepository) Update(u *userModel.User) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	stored, ok := r.users[u.Id]
+	if !ok {
+		return userModel.ErrUnknown
+	}
+	for _, val := range r.users {
+		if val.Id != u.Id && val.Email == u.Email {
+			return userModel.ErrEmailTaken
+		}
+	}
+	if stored.Username != u.Username {
+		if _, taken := r.usernames[u.Username]; taken {
+			return userModel.ErrUsernameTaken
+		}
+		delete(r.usernames, stored.Username)
+	}
 	r.users[u.Id] = u
+	r.usernames[u.Username] = u.Id
 	return nil
 }
 
@@ -51,89 +94,277 @@ func (r *userRepository) FindAll() []*userModel.User {
 func (r *userRepository) CheckLogin(username string, password string) (*userModel.User, error) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
-	for _, val := range r.users {
-		// check if the username and password match with a user in the store
-		// -------------------- IMPORTANT NOTICE: -----------------
-		// obviously it is unacceptable to compare plaintext passwords in a real-world application
-		// however, since this is a prototype application we can overlook this flaw
-		// admittedly though, it would be quite simpleto add a hashing method so only the hashes would be stored and compared
-		if username == val.Username && password == val.Password {
+	if id, ok := r.usernames[username]; ok {
+		if val := r.users[id]; val.CheckPassword(password) {
 			return val, nil
 		}
+		return nil, userModel.ErrUnknown
 	}
+	// compare against a dummy hash anyway so unknown usernames take as long as wrong passwords
+	dummyUser.CheckPassword(password)
 	return nil, userModel.ErrUnknown
 }
 
+// replaces the password hash of a user
+func (r *userRepository) UpdatePassword(id userModel.UserId, passwordHash string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if val, ok := r.users[id]; ok {
+		// the stored user is replaced by a copy with the new hash, so users that have been handed out don't change
+		updated := *val
+		updated.PasswordHash = passwordHash
+		r.users[id] = &updated
+		return nil
+	}
+	return userModel.ErrUnknown
+}
+
 // returns an instance of a user repository
 func NewUserRepository() userModel.Repository {
 	r := &userRepository{
 		users: make(map[userModel.UserId]*userModel.User),
+		usernames: make(map[string]userModel.UserId),
 	}
 
-	r.users[userModel.U0001] = userModel.Rey
-	r.users[userModel.U0002] = userModel.Kylo
-	r.users[userModel.U0003] = userModel.Luke
+	// the repository works on copies, so the sample users stay unchanged for everybody else who reads them
+	for _, u := range []*userModel.User{userModel.Rey, userModel.Kylo, userModel.Luke} {
+		sample := *u
+		r.Add(&sample)
+	}
 
 	return r
 }
 
 /* ---------- PRODUCT REPOSITORY ---------- */
+// the repository only hands out copies of the stored products, so callers never read a quantity while it is being changed
 type productRepository struct {
-	mtx		sync.RWMutex
-	products	map[productModel.ProductId]*productModel.Product
+	mtx				sync.RWMutex
+	products		map[productModel.ProductId]*productModel.Product
+	reservations	map[productModel.ReservationId]*productModel.Reservation
+}
+
+// returns the quantity of a product that is held by active reservations
+// the caller must hold the lock
+func (r *productRepository) reserved(id productModel.ProductId, now time.Time) int {
+	reserved := 0
+	for _, res := range r.reservations {
+		if res.ProductId == id && !res.Expired(now) {
+			reserved += res.Quantity
+		}
+	}
+	return reserved
+}
+
+// returns a copy of the stored product with the available quantity filled in
+// the caller must hold the lock
+func (r *productRepository) copyProduct(stored *productModel.Product, now time.Time) *productModel.Product {
+	copied := *stored
+	copied.Available = copied.Quantity - r.reserved(copied.Id, now)
+	return &copied
 }
 
 func (r *productRepository) Store(p *productModel.Product) (*productModel.Product, error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
-	r.products[p.Id] = p
+	stored := *p
+	r.products[p.Id] = &stored
 	return p, nil
 }
 
-func (r *productRepository) Add(p *productModel.Product) (*productModel.Product, error) {
-	// first check if the product even exists
-	stored, err := r.Find(p.Id)
+func (r *productRepository) Update(p *productModel.Product) (*productModel.Product, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-	if err != nil {
-		return r.Store(p)
+	stored, ok := r.products[p.Id]
+
+	if !ok {
+		return nil, productModel.ErrProductUnknown
 	}
 
-	// update the properties of the stock item
+	stored.Name = p.Name
+	stored.Description = p.Description
+	stored.Category = p.Category
+	stored.ImageUrl = p.ImageUrl
+	stored.Price = p.Price
+
+	return r.copyProduct(stored, time.Now()), nil
+}
+
+func (r *productRepository) Remove(id productModel.ProductId) error {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	stored.Quantity += p.Quantity
+	if _, ok := r.products[id]; !ok {
+		return productModel.ErrProductUnknown
+	}
+
+	delete(r.products, id)
+
+	for resId, res := range r.reservations {
+		if res.ProductId == id {
+			delete(r.reservations, resId)
+		}
+	}
 
-	return stored, nil
+	return nil
 }
 
-func (r *productRepository) Withdraw(p *productModel.Product) (*productModel.Product, error) {
-	// first check if the product even exists
-	stored, err := r.Find(p.Id)
+func (r *productRepository) Add(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	// the existence check and the update happen under the same lock, so concurrent adds can't overwrite each other
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stored, ok := r.products[item.Id]
 
-	if err != nil {
-		return nil, err
+	if !ok {
+		return nil, productModel.ErrProductUnknown
 	}
 
-	// check if there are enough items for withdrawing
-	if stored.Quantity < p.Quantity {
+	stored.Quantity += item.Quantity
+
+	return r.copyProduct(stored, time.Now()), nil
+}
+
+func (r *productRepository) Withdraw(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	// the quantity check and the update happen under the same lock, so concurrent withdrawals can't both pass the check
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stored, ok := r.products[item.Id]
+
+	if !ok {
+		return nil, productModel.ErrProductUnknown
+	}
+
+	now := time.Now()
+
+	// check if there are enough unreserved items for withdrawing
+	if stored.Quantity - r.reserved(item.Id, now) < item.Quantity {
 		return nil, productModel.ErrNotEnoughItems
 	}
 
 	// update the properties of the stock item
+	stored.Quantity -= item.Quantity
+
+	return r.copyProduct(stored, now), nil
+}
+
+func (r *productRepository) WithdrawBatch(items []*productModel.SimpleProduct) ([]*productModel.Product, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+
+	// check all items first, so nothing is withdrawn if a single one is not available
+	// the items are checked in the order of the request, so the first invalid item is always the one reported
+	merged := productModel.MergeQuantities(items)
+	for _, item := range merged {
+		stored, ok := r.products[item.Id]
+
+		if !ok {
+			return nil, productModel.ErrProductUnknown
+		}
+
+		if stored.Quantity - r.reserved(item.Id, now) < item.Quantity {
+			return nil, productModel.ErrNotEnoughItems
+		}
+	}
+
+	updated := make([]*productModel.Product, 0, len(merged))
+	for _, item := range merged {
+		stored := r.products[item.Id]
+		stored.Quantity -= item.Quantity
+
+		updated = append(updated, r.copyProduct(stored, now))
+	}
+
+	return updated, nil
+}
+
+func (r *productRepository) Reserve(res *productModel.Reservation) (*productModel.Reservation, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stored, ok := r.products[res.ProductId]
+
+	if !ok {
+		return nil, productModel.ErrProductUnknown
+	}
+
+	if stored.Quantity - r.reserved(res.ProductId, time.Now()) < res.Quantity {
+		return nil, productModel.ErrNotEnoughItems
+	}
+
+	copied := *res
+	r.reservations[res.Id] = &copied
+	return res, nil
+}
+
+func (r *productRepository) CommitReservation(id productModel.ReservationId) (*productModel.Product, error) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	stored.Quantity -= p.Quantity
+	res, ok := r.reservations[id]
+
+	if !ok {
+		return nil, productModel.ErrReservationUnknown
+	}
+
+	now := time.Now()
+
+	// the reservation is gone in any case - either it is turned into a withdrawal or it has expired
+	delete(r.reservations, id)
+
+	if res.Expired(now) {
+		return nil, productModel.ErrReservationExpired
+	}
+
+	stored, ok := r.products[res.ProductId]
+
+	if !ok {
+		return nil, productModel.ErrProductUnknown
+	}
+
+	if stored.Quantity < res.Quantity {
+		return nil, productModel.ErrNotEnoughItems
+	}
+
+	stored.Quantity -= res.Quantity
+
+	return r.copyProduct(stored, now), nil
+}
+
+func (r *productRepository) ReleaseReservation(id productModel.ReservationId) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.reservations[id]; !ok {
+		return productModel.ErrReservationUnknown
+	}
+
+	delete(r.reservations, id)
+	return nil
+}
+
+func (r *productRepository) ExpireReservations(now time.Time) ([]*productModel.Reservation, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-	return stored, nil
+	expired := []*productModel.Reservation{}
+	for id, res := range r.reservations {
+		if res.Expired(now) {
+			expired = append(expired, res)
+			delete(r.reservations, id)
+		}
+	}
+	return expired, nil
 }
 
 func (r *productRepository) Find(id productModel.ProductId) (*productModel.Product, error) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 	if val, ok := r.products[id]; ok {
-		return val, nil
+		return r.copyProduct(val, time.Now()), nil
 	}
 	return nil, productModel.ErrProductUnknown
 }
@@ -141,23 +372,109 @@ func (r *productRepository) Find(id productModel.ProductId) (*productModel.Produ
 func (r *productRepository) FindAll() []*productModel.Product {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
+	now := time.Now()
 	p := make([]*productModel.Product, 0, len(r.products))
 	for _, val := range r.products {
-		p = append(p, val)
+		p = append(p, r.copyProduct(val, now))
 	}
 	return p
 }
 
+func (r *productRepository) Search(q productModel.Query) (*productModel.Page, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	now := time.Now()
+	matching := []*productModel.Product{}
+	facets := q.NewFacetCounter()
+	for _, val := range r.products {
+		p := r.copyProduct(val, now)
+		facets.Count(p)
+		if q.Matches(p) {
+			matching = append(matching, p)
+		}
+	}
+
+	page, err := q.Paginate(matching)
+	if err != nil {
+		return nil, err
+	}
+	page.Facets = facets.Facets()
+	return page, nil
+}
+
 func NewProductRepository() productModel.Repository {
 	r := &productRepository{
 		products: make(map[productModel.ProductId]*productModel.Product),
+		reservations: make(map[productModel.ReservationId]*productModel.Reservation),
 	}
 
-	r.products[productModel.P0001] = productModel.Lightsaber
-	r.products[productModel.P0002] = productModel.MilleniumFalcon
-	r.products[productModel.P0003] = productModel.BB8
-	r.products[productModel.P0004] = productModel.Podracer
-	r.products[productModel.P0005] = productModel.CarboniteFreezer
+	r.Store(productModel.Lightsaber)
+	r.Store(productModel.MilleniumFalcon)
+	r.Store(productModel.BB8)
+	r.Store(productModel.Podracer)
+	r.Store(productModel.CarboniteFreezer)
+
+	return r
+}
+
+/* ---------- CATEGORY REPOSITORY ---------- */
+type categoryRepository struct {
+	mtx				sync.RWMutex
+	categories		map[categoryModel.CategoryId]*categoryModel.Category
+}
+
+func (r *categoryRepository) Add(c *categoryModel.Category) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.categories[c.Id]; ok {
+		return categoryModel.ErrExists
+	}
+	stored := *c
+	r.categories[c.Id] = &stored
+	return nil
+}
+
+func (r *categoryRepository) Find(id categoryModel.CategoryId) (*categoryModel.Category, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if val, ok := r.categories[id]; ok {
+		copied := *val
+		return &copied, nil
+	}
+	return nil, categoryModel.ErrUnknown
+}
+
+func (r *categoryRepository) FindAll() []*categoryModel.Category {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	c := make([]*categoryModel.Category, 0, len(r.categories))
+	for _, val := range r.categories {
+		copied := *val
+		c = append(c, &copied)
+	}
+	return c
+}
+
+func (r *categoryRepository) Remove(id categoryModel.CategoryId) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.categories[id]; !ok {
+		return categoryModel.ErrUnknown
+	}
+	delete(r.categories, id)
+	return nil
+}
+
+func NewCategoryRepository() categoryModel.Repository {
+	r := &categoryRepository{
+		categories: make(map[categoryModel.CategoryId]*categoryModel.Category),
+	}
+
+	r.Add(categoryModel.Equipment)
+	r.Add(categoryModel.Weapons)
+	r.Add(categoryModel.Utilities)
+	r.Add(categoryModel.Mobility)
+	r.Add(categoryModel.Droids)
 
 	return r
 }
@@ -257,6 +574,10 @@ func (r *cartRepository) Remove(userId userModel.UserId, productId productModel.
 	return userCart, nil
 }
 
+func (r *cartRepository) Clear(userId userModel.UserId) ([]*productModel.SimpleProduct, error) {
+	return r.StoreUserCart(userId, []*productModel.SimpleProduct{}), nil
+}
+
 func NewCartRepository() cartModel.Repository {
 	return &cartRepository{
 		carts: make(map[userModel.UserId][]*productModel.SimpleProduct),
@@ -276,17 +597,26 @@ func (r *orderRepository) Create(o *orderModel.Order) (order *orderModel.Order,
 	return o, nil
 }
 
-func (r *orderRepository) UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) (order *orderModel.Order, err error) {
-	o, err := r.Find(id)
+func (r *orderRepository) UpdateStatus(id orderModel.OrderId, change *orderModel.StatusChange) (order *orderModel.Order, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-	if err != nil {
+	stored, ok := r.orders[id]
+
+	if !ok {
+		return nil, orderModel.ErrUnknown
+	}
+
+	// the stored order is replaced instead of changed, so orders that were handed out before never change
+	updated := *stored
+	updated.History = append([]*orderModel.StatusChange{}, stored.History...)
+
+	if err := updated.ChangeStatus(change); err != nil {
 		return nil, err
 	}
 
-	r.mtx.Lock()
-	defer r.mtx.Unlock()
-	o.Status = newStatus
-	return o, nil
+	r.orders[id] = &updated
+	return &updated, nil
 }
 
 func (r *orderRepository) Find(id orderModel.OrderId) (*orderModel.Order, error) {
@@ -320,6 +650,18 @@ func (r *orderRepository) FindAllForUser(userId userModel.UserId) []*orderModel.
 	return o
 }
 
+func (r *orderRepository) Search(query orderModel.Query) (*orderModel.Page, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	matching := []*orderModel.Order{}
+	for _, val := range r.orders {
+		if query.Matches(val) {
+			matching = append(matching, val)
+		}
+	}
+	return query.Paginate(matching)
+}
+
 func NewOrderRepository() orderModel.Repository {
 	r := &orderRepository{
 		orders: make(map[orderModel.OrderId]*orderModel.Order),
@@ -329,4 +671,241 @@ func NewOrderRepository() orderModel.Repository {
 	r.orders[orderModel.O0002] = orderModel.Order2
 
 	return r
+}
+
+/* ---------- RETURN REPOSITORY ---------- */
+type returnRepository struct {
+	mtx			sync.RWMutex
+	returns		map[returnModel.ReturnId]*returnModel.Return
+}
+
+func (r *returnRepository) Create(ret *returnModel.Return) (*returnModel.Return, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.returns[ret.Id] = ret
+	return ret, nil
+}
+
+func (r *returnRepository) UpdateStatus(id returnModel.ReturnId, change *returnModel.StatusChange) (*returnModel.Return, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stored, ok := r.returns[id]
+
+	if !ok {
+		return nil, returnModel.ErrUnknown
+	}
+
+	// the stored return is replaced instead of changed, so returns that were handed out before never change
+	updated := *stored
+	updated.History = append([]*returnModel.StatusChange{}, stored.History...)
+
+	if err := updated.ChangeStatus(change); err != nil {
+		return nil, err
+	}
+
+	r.returns[id] = &updated
+	return &updated, nil
+}
+
+func (r *returnRepository) Find(id returnModel.ReturnId) (*returnModel.Return, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if val, ok := r.returns[id]; ok {
+		return val, nil
+	}
+	return nil, returnModel.ErrUnknown
+}
+
+func (r *returnRepository) FindAll() []*returnModel.Return {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	ret := make([]*returnModel.Return, 0, len(r.returns))
+	for _, val := range r.returns {
+		ret = append(ret, val)
+	}
+	return ret
+}
+
+func (r *returnRepository) FindAllForOrder(orderId orderModel.OrderId) []*returnModel.Return {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	ret := []*returnModel.Return{}
+	for _, val := range r.returns {
+		if orderId == val.OrderId {
+			ret = append(ret, val)
+		}
+	}
+	return ret
+}
+
+func NewReturnRepository() returnModel.Repository {
+	return &returnRepository{
+		returns: make(map[returnModel.ReturnId]*returnModel.Return),
+	}
+}
+
+/* ---------- PAYMENT REPOSITORY ---------- */
+type paymentRepository struct {
+	mtx			sync.RWMutex
+	payments	map[paymentModel.PaymentId]*paymentModel.Payment
+}
+
+func (r *paymentRepository) Store(p *paymentModel.Payment) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.payments[p.Id] = p
+	return nil
+}
+
+func (r *paymentRepository) Find(id paymentModel.PaymentId) (*paymentModel.Payment, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if val, ok := r.payments[id]; ok {
+		return val, nil
+	}
+	return nil, paymentModel.ErrUnknown
+}
+
+func (r *paymentRepository) FindByReference(reference string) (*paymentModel.Payment, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	for _, val := range r.payments {
+		if reference != "" && reference == val.Reference {
+			return val, nil
+		}
+	}
+	return nil, paymentModel.ErrUnknown
+}
+
+func (r *paymentRepository) FindAllForOrder(orderId orderModel.OrderId) []*paymentModel.Payment {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	p := []*paymentModel.Payment{}
+	for _, val := range r.payments {
+		if orderId == val.OrderId {
+			p = append(p, val)
+		}
+	}
+	return p
+}
+
+func NewPaymentRepository() paymentModel.Repository {
+	return &paymentRepository{
+		payments: make(map[paymentModel.PaymentId]*paymentModel.Payment),
+	}
+}
+
+/* ---------- TOKEN REPOSITORY ---------- */
+type tokenRepository struct {
+	mtx				sync.RWMutex
+	refreshTokens	map[string]*tokenModel.RefreshToken
+	revoked			map[string]time.Time
+}
+
+func (r *tokenRepository) StoreRefreshToken(t *tokenModel.RefreshToken) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// drop all refresh tokens that have expired without being used, so the store does not grow forever
+	for key, val := range r.refreshTokens {
+		if val.Expired() {
+			delete(r.refreshTokens, key)
+		}
+	}
+
+	r.refreshTokens[t.Hash] = t
+	return nil
+}
+
+func (r *tokenRepository) TakeRefreshToken(hash string) (*tokenModel.RefreshToken, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if val, ok := r.refreshTokens[hash]; ok {
+		delete(r.refreshTokens, hash)
+		return val, nil
+	}
+	return nil, tokenModel.ErrUnknown
+}
+
+func (r *tokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// drop all entries of tokens that have expired in the meantime, so the revocation list does not grow forever
+	now := time.Now()
+	for key, val := range r.revoked {
+		if now.After(val) {
+			delete(r.revoked, key)
+		}
+	}
+
+	r.revoked[jti] = expiresAt
+	return nil
+}
+
+func (r *tokenRepository) IsRevoked(jti string) bool {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	_, ok := r.revoked[jti]
+	return ok
+}
+
+func NewTokenRepository() tokenModel.Repository {
+	return &tokenRepository{
+		refreshTokens: make(map[string]*tokenModel.RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+/* ---------- IDEMPOTENCY REPOSITORY ---------- */
+type idempotencyRepository struct {
+	mtx			sync.Mutex
+	records		map[string]*idempotencyModel.Record
+}
+
+func (r *idempotencyRepository) Begin(record *idempotencyModel.Record, now time.Time) (*idempotencyModel.Record, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for key, val := range r.records {
+		if val.Expired(now) {
+			delete(r.records, key)
+		}
+	}
+
+	if existing, ok := r.records[record.Key]; ok {
+		copied := *existing
+		return &copied, nil
+	}
+
+	stored := *record
+	r.records[record.Key] = &stored
+	return nil, nil
+}
+
+func (r *idempotencyRepository) Complete(record *idempotencyModel.Record) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.records[record.Key]; !ok {
+		return idempotencyModel.ErrUnknown
+	}
+
+	stored := *record
+	r.records[record.Key] = &stored
+	return nil
+}
+
+func (r *idempotencyRepository) Remove(key string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.records, key)
+	return nil
+}
+
+func NewIdempotencyRepository() idempotencyModel.Repository {
+	return &idempotencyRepository{
+		records: make(map[string]*idempotencyModel.Record),
+	}
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package inmemory
+
+import (
+	"testing"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/product/producttest"
+)
+
+func TestProductRepository(t *testing.T) {
+	producttest.TestRepository(t, func(t *testing.T) productModel.Repository {
+		return NewProductRepository()
+	})
+}
\ No newline at end of file
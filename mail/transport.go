@@ -7,17 +7,23 @@ import (
 	"encoding/json"
 	"context"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	userModel "github.com/MICSTI/imsazon/models/user"
 )
 
 // MakeHandler returns a handler for the mail service
-func MakeHandler(ms Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(ms Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin, userModel.Service)
+
 	sendHandler := kithttp.NewServer(
-		makeSendEndpoint(ms),
+		authenticate(authorize(makeSendEndpoint(ms))),
 		decodeSendRequest,
 		encodeResponse,
 		opts...,
@@ -67,6 +73,14 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	switch err {
 	case ErrInvalidArgument:
 		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os/signal"
 	"syscall"
+	"time"
 	"github.com/MICSTI/imsazon/mail"
 	"github.com/creamdog/gonfig"
 	log2 "log"
@@ -20,6 +21,21 @@ import (
 	"github.com/MICSTI/imsazon/cart"
 	"github.com/MICSTI/imsazon/order"
 	"github.com/MICSTI/imsazon/shipping"
+	"github.com/MICSTI/imsazon/checkout"
+	"github.com/MICSTI/imsazon/catalog"
+	"github.com/MICSTI/imsazon/returns"
+	"github.com/MICSTI/imsazon/idempotency"
+	"github.com/MICSTI/imsazon/webhook"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
+	cartModel "github.com/MICSTI/imsazon/models/cart"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	tokenModel "github.com/MICSTI/imsazon/models/token"
+	idempotencyModel "github.com/MICSTI/imsazon/models/idempotency"
+	"github.com/MICSTI/imsazon/boltdb"
 )
 
 const (
@@ -50,14 +66,114 @@ func main() {
 		log2.Fatal("Could not get config from config file")
 	}
 
-	// read JWT secret from config file
-	jwtSecretString, err := config.GetString("jwt/secret", "")
+	// read the JWT signing keys config from config file
+	// the directory contains one PEM file per key, named after the key id
+	jwtKeysDir, err := config.GetString("jwt/keysDir", "")
 	if err != nil {
-		log2.Fatal("Could not get JWT secret config value")
+		log2.Fatal("Could not get JWT keys directory config value")
 	}
 
-	// parse string to byte array
-	jwtSecret := []byte(jwtSecretString)
+	jwtActiveKeyId, err := config.GetString("jwt/activeKeyId", "")
+	if err != nil {
+		log2.Fatal("Could not get JWT active key id config value")
+	}
+
+	// bcrypt cost used for hashing passwords
+	passwordCost, err := config.GetInt("auth/passwordCost", userModel.DefaultPasswordCost)
+	if err != nil {
+		log2.Fatal("Could not get password cost config value")
+	}
+
+	// storage configuration - either "inmemory" or "bolt" for the embedded database
+	storageType, err := config.GetString("storage/type", "inmemory")
+	if err != nil {
+		log2.Fatal("Could not get storage type config value")
+	}
+
+	storagePath, err := config.GetString("storage/path", "imsazon.db")
+	if err != nil {
+		log2.Fatal("Could not get storage path config value")
+	}
+
+	// interval in seconds in which expired stock reservations are removed
+	reservationReaperInterval, err := config.GetInt("stock/reservationReaperInterval", 60)
+	if err != nil {
+		log2.Fatal("Could not get reservation reaper interval config value")
+	}
+
+	// order pricing - the tax rate is in basis points (2000 = 20%), the amounts are in the minor unit of the currency (cents)
+	taxRate, err := config.GetInt("order/taxRate", int(orderModel.DefaultPricingPolicy.TaxRate))
+	if err != nil {
+		log2.Fatal("Could not get tax rate config value")
+	}
+
+	shippingFee, err := config.GetInt("order/shippingFee", int(orderModel.DefaultPricingPolicy.ShippingFee))
+	if err != nil {
+		log2.Fatal("Could not get shipping fee config value")
+	}
+
+	freeShippingThreshold, err := config.GetInt("order/freeShippingThreshold", int(orderModel.DefaultPricingPolicy.FreeShippingThreshold))
+	if err != nil {
+		log2.Fatal("Could not get free shipping threshold config value")
+	}
+
+	pricing := orderModel.PricingPolicy{
+		TaxRate:				int64(taxRate),
+		ShippingFee:			int64(shippingFee),
+		FreeShippingThreshold:	int64(freeShippingThreshold),
+	}
+
+	// number of days after shipping in which items can be returned
+	returnWindow, err := config.GetInt("returns/window", int(returns.DefaultWindow / (time.Hour * 24)))
+	if err != nil {
+		log2.Fatal("Could not get return window config value")
+	}
+
+	// number of hours in which requests with the same idempotency key get the stored response
+	idempotencyWindow, err := config.GetInt("idempotency/window", int(idempotency.DefaultWindow / time.Hour))
+	if err != nil {
+		log2.Fatal("Could not get idempotency window config value")
+	}
+
+	// payment gateway - either "simulator" or "stripe" for a Stripe-compatible API
+	paymentGateway, err := config.GetString("payment/gateway", "simulator")
+	if err != nil {
+		log2.Fatal("Could not get payment gateway config value")
+	}
+
+	// simulated time every payment takes, in milliseconds
+	paymentLatency, err := config.GetInt("payment/latency", 1000)
+	if err != nil {
+		log2.Fatal("Could not get payment latency config value")
+	}
+
+	stripeUrl, err := config.GetString("payment/stripe/url", payment.DefaultStripeUrl)
+	if err != nil {
+		log2.Fatal("Could not get Stripe url config value")
+	}
+
+	stripeApiKey, err := config.GetString("payment/stripe/apiKey", "")
+	if err != nil {
+		log2.Fatal("Could not get Stripe API key config value")
+	}
+
+	// shared secret the payment provider signs its webhook events with
+	webhookSecret, err := config.GetString("payment/webhook/secret", "")
+	if err != nil {
+		log2.Fatal("Could not get webhook secret config value")
+	}
+
+	// number of seconds after which a signed webhook event is rejected
+	webhookTolerance, err := config.GetInt("payment/webhook/tolerance", int(webhook.DefaultTolerance / time.Second))
+	if err != nil {
+		log2.Fatal("Could not get webhook tolerance config value")
+	}
+
+	// number of hours the ids of processed webhook events are kept to recognize repeated deliveries
+	webhookRetention, err := config.GetInt("payment/webhook/retention", int(webhook.DefaultRetention / time.Hour))
+	if err != nil {
+		log2.Fatal("Could not get webhook retention config value")
+	}
 
 	// Mail configuration
 	mailHost, err := config.GetString("mail/host", "")
@@ -101,13 +217,60 @@ func main() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 
-	// init in-memory repository stores here
+	var jwtKeys *auth.KeySet
+	if jwtKeysDir != "" {
+		jwtKeys, err = auth.LoadKeySet(jwtKeysDir, jwtActiveKeyId)
+	} else {
+		logger.Log("msg", "no JWT keys directory configured, using a generated key - all tokens become invalid on restart")
+		jwtKeys, err = auth.GenerateKeySet()
+	}
+	if err != nil {
+		log2.Fatal("Could not load JWT signing keys: ", err)
+	}
+
+	// init the repository stores here
 	var (
+		users userModel.Repository
+		products productModel.Repository
+		categories categoryModel.Repository
+		carts cartModel.Repository
+		orders orderModel.Repository
+		returnRequests returnModel.Repository
+		payments paymentModel.Repository
+		tokens tokenModel.Repository
+		idempotencyRecords idempotencyModel.Repository
+	)
+
+	switch storageType {
+	case "inmemory":
 		users = inmemory.NewUserRepository()
 		products = inmemory.NewProductRepository()
+		categories = inmemory.NewCategoryRepository()
 		carts = inmemory.NewCartRepository()
 		orders = inmemory.NewOrderRepository()
-	)
+		returnRequests = inmemory.NewReturnRepository()
+		payments = inmemory.NewPaymentRepository()
+		tokens = inmemory.NewTokenRepository()
+		idempotencyRecords = inmemory.NewIdempotencyRepository()
+	case "bolt":
+		db, err := boltdb.Open(storagePath)
+		if err != nil {
+			log2.Fatal("Could not open database: ", err)
+		}
+		defer db.Close()
+
+		users = boltdb.NewUserRepository(db)
+		products = boltdb.NewProductRepository(db)
+		categories = boltdb.NewCategoryRepository(db)
+		carts = boltdb.NewCartRepository(db)
+		orders = boltdb.NewOrderRepository(db)
+		returnRequests = boltdb.NewReturnRepository(db)
+		payments = boltdb.NewPaymentRepository(db)
+		tokens = boltdb.NewTokenRepository(db)
+		idempotencyRecords = boltdb.NewIdempotencyRepository(db)
+	default:
+		log2.Fatal("Unknown storage type: ", storageType)
+	}
 
 	// all services are initialized here
 	var hs hello.Service
@@ -115,7 +278,7 @@ func main() {
 	hs = hello.NewLoggingService(log.With(logger, "component", "hello"), hs)
 
 	var as auth.Service
-	as = auth.NewService(jwtSecret, users)
+	as = auth.NewService(jwtKeys, users, tokens, passwordCost)
 	as = auth.NewLoggingService(log.With(logger, "component", "auth"), as)
 
 	var ms mail.Service
@@ -123,11 +286,28 @@ func main() {
 	ms = mail.NewLoggingService(log.With(logger, "component", "mail"), ms)
 
 	var sts stock.Service
-	sts = stock.NewService(products)
+	sts = stock.NewService(products, categories)
 	sts = stock.NewLoggingService(log.With(logger, "component", "stock"), sts)
 
+	stopReaper := stock.StartReaper(sts, time.Duration(reservationReaperInterval) * time.Second)
+	defer stopReaper()
+
+	var cats catalog.Service
+	cats = catalog.NewService(products, categories)
+	cats = catalog.NewLoggingService(log.With(logger, "component", "catalog"), cats)
+
+	var gateway payment.Gateway
+	switch paymentGateway {
+	case "simulator":
+		gateway = payment.NewSimulator(time.Duration(paymentLatency) * time.Millisecond)
+	case "stripe":
+		gateway = payment.NewStripeGateway(stripeUrl, stripeApiKey, &http.Client{Timeout: time.Second * 30})
+	default:
+		log2.Fatal("Unknown payment gateway: ", paymentGateway)
+	}
+
 	var ps payment.Service
-	ps = payment.NewService()
+	ps = payment.NewService(gateway, payments)
 	ps = payment.NewLoggingService(log.With(logger, "component", "payment"), ps)
 
 	var cs cart.Service
@@ -135,27 +315,53 @@ func main() {
 	cs = cart.NewLoggingService(log.With(logger, "component", "cart"), cs)
 
 	var ors order.Service
-	ors = order.NewService(orders)
+	ors = order.NewService(orders, products, pricing, users, sts, ps, ms)
 	ors = order.NewLoggingService(log.With(logger, "component", "order"), ors)
 
+	var rs returns.Service
+	rs = returns.NewService(returnRequests, ors, sts, ps, time.Duration(returnWindow) * time.Hour * 24)
+	rs = returns.NewLoggingService(log.With(logger, "component", "returns"), rs)
+
 	var shs shipping.Service
-	shs = shipping.NewService(testMailRecipient)
+	shs = shipping.NewService(testMailRecipient, as)
 	shs = shipping.NewLoggingService(log.With(logger, "component", "shipping"), shs)
 
+	var cos checkout.Service
+	cos = checkout.NewService(cs, ors, sts, ps)
+	cos = checkout.NewLoggingService(log.With(logger, "component", "checkout"), cos)
+
+	var ws webhook.Service
+	ws = webhook.NewService(ps, ors, idempotencyRecords, time.Duration(webhookRetention) * time.Hour)
+	ws = webhook.NewLoggingService(log.With(logger, "component", "webhook"), ws)
+
 	// now comes the HTTP REST API stuff
 	httpLogger := log.With(logger, "component", "http")
 
+	// retries of these requests with the same idempotency key are answered from the store instead of being executed again
+	idempotent := idempotency.NewMiddleware(idempotencyRecords, as, time.Duration(idempotencyWindow) * time.Hour, log.With(logger, "component", "idempotency"))
+
 	// init router
 	mux := http.NewServeMux()
 
+	paymentHandler := payment.MakeHandler(ps, as, httpLogger)
+	orderHandler := order.MakeHandler(ors, as, httpLogger)
+
 	mux.Handle("/hello/", hello.MakeHandler(hs, httpLogger))
 	mux.Handle("/auth/", auth.MakeHandler(as, httpLogger))
-	mux.Handle("/mail/", mail.MakeHandler(ms, httpLogger))
-	mux.Handle("/stock/", stock.MakeHandler(sts, httpLogger))
-	mux.Handle("/payment/", payment.MakeHandler(ps, httpLogger))
-	mux.Handle("/cart/", cart.MakeHandler(cs, httpLogger))
-	mux.Handle("/order/", order.MakeHandler(ors, httpLogger))
-	mux.Handle("/ship/", shipping.MakeHandler(shs, httpLogger))
+	mux.Handle("/mail/", mail.MakeHandler(ms, as, httpLogger))
+	mux.Handle("/stock/", stock.MakeHandler(sts, as, httpLogger))
+	mux.Handle("/catalog/", catalog.MakeHandler(cats, as, httpLogger))
+	mux.Handle("/payment/", paymentHandler)
+	mux.Handle("/payment/charge", idempotent(paymentHandler))
+	mux.Handle("/payment/authorize", idempotent(paymentHandler))
+	mux.Handle("/payment/refund/", idempotent(paymentHandler))
+	mux.Handle("/payment/webhook", webhook.MakeHandler(ws, webhookSecret, time.Duration(webhookTolerance) * time.Second, httpLogger))
+	mux.Handle("/cart/", cart.MakeHandler(cs, as, httpLogger))
+	mux.Handle("/order/", orderHandler)
+	mux.Handle("/order/create", idempotent(orderHandler))
+	mux.Handle("/returns/", returns.MakeHandler(rs, as, httpLogger))
+	mux.Handle("/ship/", idempotent(shipping.MakeHandler(shs, as, httpLogger)))
+	mux.Handle("/checkout", checkout.MakeHandler(cos, as, httpLogger))
 
 	http.Handle("/", accessControl(mux))
 
@@ -166,7 +372,7 @@ func main() {
 		errs <- http.ListenAndServe(*httpAddr, nil)
 	}()
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT)
 		errs <- fmt.Errorf("%s", <-c)
 	}()
@@ -176,11 +382,9 @@ func main() {
 
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO auth code would go here
-
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Idempotency-Key")
 
 		if r.Method == "OPTIONS" {
 			return
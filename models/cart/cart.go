@@ -15,4 +15,7 @@ type Repository interface {
 
 	// deletes an item from the user's cart
 	Remove(id user.UserId, productId product.ProductId) ([]*product.SimpleProduct, error)
+
+	// removes all items from the user's cart
+	Clear(id user.UserId) ([]*product.SimpleProduct, error)
 }
\ No newline at end of file
@@ -0,0 +1,130 @@
+// This package contains the product category model
+
+package category
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CategoryId uniquely identifies a category, it is also used in URLs and as category of a product
+type CategoryId string
+
+func (c CategoryId) String() string {
+	return string(c)
+}
+
+// Category groups products, categories without a parent are at the top of the tree
+type Category struct {
+	Id				CategoryId		`json:"id"`
+	Name			string			`json:"name"`
+	ParentId		CategoryId		`json:"parentId,omitempty"`
+}
+
+func New(id CategoryId, name string, parentId CategoryId) *Category {
+	return &Category{
+		Id:				id,
+		Name:			name,
+		ParentId:		parentId,
+	}
+}
+
+var nonSlugCharacters = regexp.MustCompile("[^a-z0-9]+")
+
+// IdFromName turns the category name into a readable id, e.g. "Space Ships" becomes "space-ships"
+func IdFromName(name string) CategoryId {
+	return CategoryId(strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-"))
+}
+
+// Node is a category together with its sub categories
+type Node struct {
+	*Category
+	Children		[]*Node			`json:"children"`
+}
+
+// BuildTree arranges the categories as a tree, the categories on every level are sorted by name
+func BuildTree(categories []*Category) []*Node {
+	nodes := make(map[CategoryId]*Node)
+	for _, c := range categories {
+		nodes[c.Id] = &Node{Category: c, Children: []*Node{}}
+	}
+
+	roots := []*Node{}
+	for _, c := range categories {
+		if parent, ok := nodes[c.ParentId]; ok && c.ParentId != "" {
+			parent.Children = append(parent.Children, nodes[c.Id])
+		} else {
+			roots = append(roots, nodes[c.Id])
+		}
+	}
+
+	sortNodes(roots)
+	return roots
+}
+
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+}
+
+// Descendants returns the id of the category and the ids of all categories below it
+func Descendants(categories []*Category, id CategoryId) []CategoryId {
+	children := make(map[CategoryId][]CategoryId)
+	for _, c := range categories {
+		if c.ParentId != "" {
+			children[c.ParentId] = append(children[c.ParentId], c.Id)
+		}
+	}
+
+	ids := []CategoryId{}
+	queue := []CategoryId{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		ids = append(ids, current)
+		queue = append(queue, children[current]...)
+	}
+	return ids
+}
+
+// Ancestors returns the ids of all categories above the category, starting with its parent
+func Ancestors(categories []*Category, id CategoryId) []CategoryId {
+	parents := make(map[CategoryId]CategoryId)
+	for _, c := range categories {
+		parents[c.Id] = c.ParentId
+	}
+
+	ids := []CategoryId{}
+	// the length check protects against cycles in corrupted data
+	for parent := parents[id]; parent != "" && len(ids) < len(categories); parent = parents[parent] {
+		ids = append(ids, parent)
+	}
+	return ids
+}
+
+// Repository provides access to a category store
+type Repository interface {
+	// stores a new category, fails with ErrExists if the id is already used
+	Add(category *Category) error
+
+	// tries to find a category by CategoryId
+	Find(id CategoryId) (*Category, error)
+
+	// returns all categories
+	FindAll() []*Category
+
+	// removes a category
+	Remove(id CategoryId) error
+}
+
+// ErrUnknown is returned when a category could not be found
+var ErrUnknown = errors.New("Unknown category")
+
+// ErrExists is returned when a category with the same id already exists
+var ErrExists = errors.New("A category with this name already exists")
\ No newline at end of file
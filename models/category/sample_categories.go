@@ -0,0 +1,10 @@
+package category
+
+// sample categories
+var (
+	Equipment = New("equipment", "Equipment", "")
+	Weapons = New("weapons", "Weapons", Equipment.Id)
+	Utilities = New("utilities", "Utilities", Equipment.Id)
+	Mobility = New("mobility", "Mobility", "")
+	Droids = New("droids", "Droids", "")
+)
\ No newline at end of file
@@ -0,0 +1,53 @@
+// This package contains the model for the stored responses of requests with an idempotency key
+
+package idempotency
+
+import (
+	"errors"
+	"time"
+)
+
+// Record is the stored result of a request with an idempotency key
+// it is created when the request starts and completed with the response once the request has been handled
+type Record struct {
+	// identifies the request together with the user and the route it was sent to
+	Key				string
+	// hash of the request body, a retry has to send exactly the same request
+	Fingerprint		string
+	// false while the first request is still being handled
+	Completed		bool
+	StatusCode		int
+	ContentType		string
+	Body			[]byte
+	ExpiresAt		time.Time
+}
+
+func New(key string, fingerprint string, expiresAt time.Time) *Record {
+	return &Record{
+		Key:			key,
+		Fingerprint:	fingerprint,
+		ExpiresAt:		expiresAt,
+	}
+}
+
+// Expired returns true if the record is no longer used at the passed time
+func (r *Record) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// Repository provides access to the idempotency record store
+type Repository interface {
+	// stores the record if there is no active record with the same key yet
+	// returns the active record if there is one, the new record is not stored in this case
+	// expired records are dropped, so the store does not grow forever
+	Begin(record *Record, now time.Time) (existing *Record, err error)
+
+	// replaces a stored record with its completed version
+	Complete(record *Record) error
+
+	// removes a record, so the key can be used again
+	Remove(key string) error
+}
+
+// ErrUnknown is used when a record could not be found
+var ErrUnknown = errors.New("Unknown idempotency key")
\ No newline at end of file
@@ -0,0 +1,342 @@
+// This package contains the money model - amounts are stored as integers in the minor unit of their currency (e.g. cents),
+// so prices and totals are always exact
+
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+func (c Currency) String() string {
+	return string(c)
+}
+
+// supported currencies
+const (
+	EUR Currency = "EUR"
+	USD Currency = "USD"
+	GBP Currency = "GBP"
+	CHF Currency = "CHF"
+	JPY Currency = "JPY"
+)
+
+// DefaultCurrency is used for amounts that were stored before they had a currency
+const DefaultCurrency = EUR
+
+// the number of digits after the decimal separator per currency
+var minorUnits = map[Currency]int{
+	EUR: 2,
+	USD: 2,
+	GBP: 2,
+	CHF: 2,
+	JPY: 0,
+}
+
+// ParseCurrency returns the currency for its ISO 4217 code
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !c.Valid() {
+		return "", ErrUnknownCurrency
+	}
+	return c, nil
+}
+
+// Valid returns true if the currency is supported
+func (c Currency) Valid() bool {
+	_, ok := minorUnits[c]
+	return ok
+}
+
+// returns 10 to the power of the number of minor units
+func (c Currency) factor() int64 {
+	f := int64(1)
+	for i := 0; i < minorUnits[c]; i++ {
+		f *= 10
+	}
+	return f
+}
+
+// Money is an amount in the minor unit of its currency, e.g. 39999.99 EUR is stored as 3999999
+type Money struct {
+	Amount			int64
+	Currency		Currency
+}
+
+func New(amount int64, currency Currency) Money {
+	return Money{
+		Amount:		amount,
+		Currency:	currency,
+	}
+}
+
+// Zero returns an amount of 0 in the currency
+func Zero(currency Currency) Money {
+	return New(0, currency)
+}
+
+// Parse reads a decimal amount like "39999.99" in the currency
+// more decimal places than the currency has are rejected instead of being rounded
+func Parse(amount string, currency Currency) (Money, error) {
+	if !currency.Valid() {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i + 1:]
+	}
+
+	digits := minorUnits[currency]
+	if whole == "" || len(fraction) > digits || strings.ContainsAny(whole + fraction, "+-") {
+		return Money{}, ErrInvalidAmount
+	}
+
+	fraction += strings.Repeat("0", digits - len(fraction))
+
+	value, err := strconv.ParseInt(whole + fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	if negative {
+		value = -value
+	}
+
+	return New(value, currency), nil
+}
+
+// FromFloat converts a decimal number into the currency, rounding half away from zero
+// it is only meant for reading amounts that were stored as floating point numbers, amounts beyond int64 are capped
+func FromFloat(amount float64, currency Currency) Money {
+	minor := math.Round(amount * float64(currency.factor()))
+	switch {
+	case minor >= math.MaxInt64:
+		return New(math.MaxInt64, currency)
+	case minor <= math.MinInt64:
+		return New(math.MinInt64, currency)
+	}
+	return New(int64(minor), currency)
+}
+
+// Decimal returns the amount as decimal string without currency, e.g. "39999.99"
+func (m Money) Decimal() string {
+	digits := minorUnits[m.Currency]
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if digits == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+
+	f := m.Currency.factor()
+	return fmt.Sprintf("%s%d.%0*d", sign, amount / f, digits, amount % f)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency.String()
+}
+
+// IsZero returns true if the amount is 0, regardless of the currency
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive returns true if the amount is greater than 0
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative returns true if the amount is lower than 0
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns the sum of both amounts, which must have the same currency
+// returns ErrOverflow if the sum does not fit into an int64
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(sum, m.Currency), nil
+}
+
+// Sub returns the difference of both amounts, which must have the same currency
+// returns ErrOverflow if the difference does not fit into an int64
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	difference := m.Amount - other.Amount
+	if (other.Amount > 0 && difference > m.Amount) || (other.Amount < 0 && difference < m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(difference, m.Currency), nil
+}
+
+// Mul multiplies the amount by a whole number, e.g. the quantity of an order line
+// the result is capped at the limits of int64, so it can't wrap around - adding anything to a capped amount fails with ErrOverflow
+func (m Money) Mul(n int64) Money {
+	return New(saturate(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))), m.Currency)
+}
+
+// MulRatio multiplies the amount by numerator / denominator and rounds the result half away from zero to the minor unit
+// e.g. MulRatio(20, 100) returns 20 percent of the amount
+// the result is capped at the limits of int64 like the one of Mul
+func (m Money) MulRatio(numerator int64, denominator int64) Money {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator))
+	return New(saturate(divRound(product, big.NewInt(denominator))), m.Currency)
+}
+
+// Cmp compares both amounts, which must have the same currency
+// returns -1 if m is lower than other, 0 if both are equal and 1 if m is greater
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Sum adds up all amounts, which must have the same currency
+func Sum(currency Currency, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// divides and rounds half away from zero
+func divRound(a *big.Int, b *big.Int) *big.Int {
+	negative := a.Sign() * b.Sign() < 0
+
+	absA := new(big.Int).Abs(a)
+	absB := new(big.Int).Abs(b)
+
+	// adding half of the divisor before truncating rounds halves up
+	q := absA.Quo(absA.Add(absA, new(big.Int).Quo(absB, big.NewInt(2))), absB)
+	if negative {
+		q.Neg(q)
+	}
+	return q
+}
+
+// returns the value of x, capped at the limits of int64
+func saturate(x *big.Int) int64 {
+	switch {
+	case x.IsInt64():
+		return x.Int64()
+	case x.Sign() > 0:
+		return math.MaxInt64
+	}
+	return math.MinInt64
+}
+
+type jsonMoney struct {
+	Amount			string			`json:"amount"`
+	Currency		Currency		`json:"currency"`
+}
+
+// MarshalJSON encodes the amount as decimal string, e.g. {"amount": "39999.99", "currency": "EUR"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON reads the encoding of MarshalJSON as well as plain numbers or decimal strings,
+// which were used before amounts had a currency - those are read in the DefaultCurrency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	decoded, err := Decode(data, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = decoded
+	return nil
+}
+
+// Decode reads an amount from JSON, plain numbers or decimal strings without a currency are read in the passed currency
+func Decode(data []byte, currency Currency) (Money, error) {
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("{")) {
+		var body struct {
+			Amount			json.RawMessage		`json:"amount"`
+			Currency		string				`json:"currency"`
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return Money{}, err
+		}
+		if body.Currency != "" {
+			c, err := ParseCurrency(body.Currency)
+			if err != nil {
+				return Money{}, err
+			}
+			currency = c
+		}
+		data = bytes.TrimSpace(body.Amount)
+	}
+
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return Zero(currency), nil
+	}
+
+	if bytes.HasPrefix(data, []byte("\"")) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return Money{}, err
+		}
+		return Parse(s, currency)
+	}
+
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	if !currency.Valid() {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	return FromFloat(f, currency), nil
+}
+
+// ErrUnknownCurrency is returned for currency codes that are not supported
+var ErrUnknownCurrency = errors.New("Unknown currency")
+
+// ErrInvalidAmount is returned when an amount could not be read
+var ErrInvalidAmount = errors.New("Invalid amount")
+
+// ErrOverflow is returned when the result of a calculation is too large to be stored
+var ErrOverflow = errors.New("The amount is too large")
+
+// ErrCurrencyMismatch is returned when amounts in different currencies are combined
+var ErrCurrencyMismatch = errors.New("The amounts have different currencies")
\ No newline at end of file
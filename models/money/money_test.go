@@ -0,0 +1,205 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount		string
+		currency	Currency
+		want		Money
+		err			error
+	}{
+		{"39999.99", EUR, New(3999999, EUR), nil},
+		{"12499", EUR, New(1249900, EUR), nil},
+		{"0.5", EUR, New(50, EUR), nil},
+		{" 4.99 ", USD, New(499, USD), nil},
+		{"-1.05", EUR, New(-105, EUR), nil},
+		{"0", EUR, New(0, EUR), nil},
+		{"1500", JPY, New(1500, JPY), nil},
+		{"1.999", EUR, Money{}, ErrInvalidAmount},
+		{"1.5", JPY, Money{}, ErrInvalidAmount},
+		{".50", EUR, Money{}, ErrInvalidAmount},
+		{"--1", EUR, Money{}, ErrInvalidAmount},
+		{"+1", EUR, Money{}, ErrInvalidAmount},
+		{"1,50", EUR, Money{}, ErrInvalidAmount},
+		{"abc", EUR, Money{}, ErrInvalidAmount},
+		{"", EUR, Money{}, ErrInvalidAmount},
+		{"99999999999999999999", EUR, Money{}, ErrInvalidAmount},
+		{"1.00", "XYZ", Money{}, ErrUnknownCurrency},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.amount, test.currency)
+		if got != test.want || err != test.err {
+			t.Errorf("Parse(%q, %s) = %v, %v, want %v, %v", test.amount, test.currency, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money		Money
+		want		string
+	}{
+		{New(3999999, EUR), "39999.99"},
+		{New(5, EUR), "0.05"},
+		{New(50, EUR), "0.50"},
+		{New(0, EUR), "0.00"},
+		{New(-105, EUR), "-1.05"},
+		{New(-5, EUR), "-0.05"},
+		{New(1500, JPY), "1500"},
+		{New(-1500, JPY), "-1500"},
+	}
+
+	for _, test := range tests {
+		if got := test.money.Decimal(); got != test.want {
+			t.Errorf("%#v.Decimal() = %q, want %q", test.money, got, test.want)
+		}
+	}
+}
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		amount		int64
+		numerator	int64
+		denominator	int64
+		want		int64
+	}{
+		{1000, 20, 100, 200},
+		// 0.5 cents are rounded away from zero
+		{1, 1, 2, 1},
+		{-1, 1, 2, -1},
+		{1, -1, 2, -1},
+		{-1, 1, -2, 1},
+		{5, 1, 10, 1},
+		{-5, 1, 10, -1},
+		// just below half
+		{4, 1, 10, 0},
+		{-4, 1, 10, 0},
+		{1999998, 2000, 10000, 400000},
+		{333, 1, 3, 111},
+		{0, 20, 100, 0},
+		// the intermediate product does not fit into an int64, the result does
+		{math.MaxInt64, 3, 3, math.MaxInt64},
+		{math.MaxInt64, 2, 1, math.MaxInt64},
+		{math.MinInt64, 2, 1, math.MinInt64},
+	}
+
+	for _, test := range tests {
+		got := New(test.amount, EUR).MulRatio(test.numerator, test.denominator)
+		if want := New(test.want, EUR); got != want {
+			t.Errorf("MulRatio(%d, %d) of %d = %v, want %v", test.numerator, test.denominator, test.amount, got.Amount, want.Amount)
+		}
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, want	int64
+	}{
+		{7, 2, 4},
+		{-7, 2, -4},
+		{7, -2, -4},
+		{-7, -2, 4},
+		{6, 4, 2},
+		{5, 4, 1},
+		{-5, 4, -1},
+		{0, 3, 0},
+	}
+
+	for _, test := range tests {
+		a, b := big.NewInt(test.a), big.NewInt(test.b)
+		if got := divRound(a, b).Int64(); got != test.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if a.Int64() != test.a || b.Int64() != test.b {
+			t.Errorf("divRound(%d, %d) changed its arguments to %d, %d", test.a, test.b, a.Int64(), b.Int64())
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	max := New(math.MaxInt64, EUR)
+	min := New(math.MinInt64, EUR)
+
+	if _, err := max.Add(New(1, EUR)); err != ErrOverflow {
+		t.Errorf("Add beyond the maximum returned %v, want %v", err, ErrOverflow)
+	}
+	if _, err := min.Add(New(-1, EUR)); err != ErrOverflow {
+		t.Errorf("Add beyond the minimum returned %v, want %v", err, ErrOverflow)
+	}
+	if _, err := min.Sub(New(1, EUR)); err != ErrOverflow {
+		t.Errorf("Sub beyond the minimum returned %v, want %v", err, ErrOverflow)
+	}
+	if _, err := max.Sub(New(-1, EUR)); err != ErrOverflow {
+		t.Errorf("Sub beyond the maximum returned %v, want %v", err, ErrOverflow)
+	}
+	if got, err := max.Add(New(-1, EUR)); err != nil || got.Amount != math.MaxInt64 - 1 {
+		t.Errorf("Add within the limits returned %v, %v", got, err)
+	}
+
+	if got := New(math.MaxInt64 / 2 + 1, EUR).Mul(2); got != max {
+		t.Errorf("Mul beyond the maximum = %v, want %v", got, max)
+	}
+	if got := New(math.MaxInt64 / 2 + 1, EUR).Mul(-2); got != min {
+		t.Errorf("Mul beyond the minimum = %v, want %v", got, min)
+	}
+	if got := New(1999, EUR).Mul(3); got != New(5997, EUR) {
+		t.Errorf("Mul = %v, want %v", got, New(5997, EUR))
+	}
+
+	if got := FromFloat(1e30, EUR); got != max {
+		t.Errorf("FromFloat beyond the maximum = %v, want %v", got, max)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data		string
+		want		Money
+	}{
+		{`{"amount": "39999.99", "currency": "EUR"}`, New(3999999, EUR)},
+		{`{"amount": "1500", "currency": "jpy"}`, New(1500, JPY)},
+		{`{"amount": 4.99}`, New(499, DefaultCurrency)},
+		// prices used to be stored as plain floating point numbers
+		{`39999.99`, New(3999999, DefaultCurrency)},
+		{`999.99`, New(99999, DefaultCurrency)},
+		{`30000`, New(3000000, DefaultCurrency)},
+		{`0.005`, New(1, DefaultCurrency)},
+		{`"12.50"`, New(1250, DefaultCurrency)},
+		{`null`, Zero(DefaultCurrency)},
+	}
+
+	for _, test := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(test.data), &got); err != nil || got != test.want {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", test.data, got, err, test.want)
+		}
+	}
+
+	for _, data := range []string{`{"amount": "1.00", "currency": "XYZ"}`, `"1.999"`, `true`} {
+		var got Money
+		if err := json.Unmarshal([]byte(data), &got); err == nil {
+			t.Errorf("Unmarshal(%s) = %v, want an error", data, got)
+		}
+	}
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{New(3999999, EUR), New(-105, USD), New(1500, JPY), Zero(GBP)} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil || got != m {
+			t.Errorf("round trip of %v returned %v, %v from %s", m, got, err, data)
+		}
+	}
+}
\ No newline at end of file
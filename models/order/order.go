@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"time"
 	"github.com/MICSTI/imsazon/models/user"
+	"github.com/MICSTI/imsazon/models/money"
 )
 
 // OrderId uniquely identifies an order
@@ -26,6 +27,9 @@ const (
 	Shipped
 	ReturnRequested
 	Returned
+	Cancelled
+	// the card holder has disputed the payment with their bank
+	PaymentDisputed
 )
 
 func (s OrderStatus) String() string {
@@ -42,34 +46,191 @@ func (s OrderStatus) String() string {
 		return "Return Requested"
 	case Returned:
 		return "Returned"
+	case Cancelled:
+		return "Cancelled"
+	case PaymentDisputed:
+		return "Payment Disputed"
 	}
 	return "Unknown order status"
 }
 
+// the allowed status changes - every status maps to the statuses an order can move on to
+var transitions = map[OrderStatus][]OrderStatus{
+	// orders can be cancelled as long as they have not been shipped
+	Created:			{PaymentSuccessful, PaymentError, Cancelled},
+	PaymentError:		{PaymentSuccessful, Cancelled},
+	PaymentSuccessful:	{Shipped, Cancelled, PaymentDisputed},
+	Shipped:			{ReturnRequested, PaymentDisputed},
+	// a rejected return request puts the order back to "Shipped"
+	ReturnRequested:	{Returned, Shipped},
+	Returned:			{},
+	Cancelled:			{},
+	// disputes are settled with the bank, the order is not processed any further
+	PaymentDisputed:	{},
+}
+
+// Valid returns true if the status is one of the defined order statuses
+func (s OrderStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo returns true if an order with this status may be changed to the next status
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is an entry in the status history of an order
+type StatusChange struct {
+	Status			OrderStatus					`json:"status"`
+	ChangedAt		time.Time					`json:"changedAt"`
+	// the id of the user or service that changed the status
+	Actor			string						`json:"actor"`
+	// why the status was changed, e.g. the reason for a cancellation
+	Reason			string						`json:"reason,omitempty"`
+}
+
+// LineItem is a product of an order together with the price it had when the order was created
+type LineItem struct {
+	ProductId		product.ProductId			`json:"id"`
+	Quantity		int							`json:"quantity"`
+	Name			string						`json:"name"`
+	UnitPrice		money.Money					`json:"unitPrice"`
+	// unit price times quantity
+	Total			money.Money					`json:"total"`
+}
+
+// NewLineItem takes a snapshot of the product name and price
+func NewLineItem(p *product.Product, quantity int) *LineItem {
+	return &LineItem{
+		ProductId:		p.Id,
+		Quantity:		quantity,
+		Name:			p.Name,
+		UnitPrice:		p.Price,
+		Total:			p.Price.Mul(int64(quantity)),
+	}
+}
+
 type Order struct {
 	Id			OrderId						`json:"id"`
 	UserId		user.UserId					`json:"userId"`
-	Date		string						`json:"date"`
+	CreatedAt	time.Time					`json:"createdAt"`
+	// the time of the last status change
+	UpdatedAt	time.Time					`json:"updatedAt"`
 	Status		OrderStatus					`json:"status"`
-	Items		[]*product.SimpleProduct	`json:"items"`
+	Items		[]*LineItem					`json:"items"`
+	// sum of all line totals
+	Subtotal	money.Money					`json:"subtotal"`
+	Tax			money.Money					`json:"tax"`
+	ShippingFee	money.Money					`json:"shippingFee"`
+	// subtotal plus tax plus shipping fee, this is the amount that is charged
+	Total		money.Money					`json:"total"`
+	// all status changes, starting with the creation of the order
+	History		[]*StatusChange				`json:"history"`
 }
 
+// New creates an order for the items, names and prices are filled in when the order service creates the order
 func New(id OrderId, userId user.UserId, items []*product.SimpleProduct) *Order {
+	lineItems := make([]*LineItem, 0, len(items))
+	for _, item := range items {
+		// missing items are kept, so the validation of the order service can report them with their line
+		if item == nil {
+			lineItems = append(lineItems, nil)
+			continue
+		}
+		lineItems = append(lineItems, &LineItem{ProductId: item.Id, Quantity: item.Quantity})
+	}
+
 	return &Order{
 		Id:			id,
 		UserId:		userId,
 		Status:		Created,
-		Items:		items,
+		Items:		lineItems,
 	}
 }
 
+// ChangeStatus moves the order to the status of the change and records the change in its history
+// returns ErrInvalidOperation if the transition is not allowed
+func (o *Order) ChangeStatus(change *StatusChange) error {
+	if !o.Status.CanTransitionTo(change.Status) {
+		return ErrInvalidOperation
+	}
+
+	o.Status = change.Status
+	o.UpdatedAt = change.ChangedAt
+	o.History = append(o.History, change)
+	return nil
+}
+
+// PricingPolicy contains the tax rate and shipping fee applied to new orders
+// product prices are net prices, the tax is added on top of the subtotal
+type PricingPolicy struct {
+	// in basis points, e.g. 2000 for 20 percent
+	TaxRate					int64
+	// in the minor unit of the order currency
+	ShippingFee				int64
+	// orders with a subtotal of at least this amount ship for free, 0 disables free shipping
+	FreeShippingThreshold	int64
+}
+
+// DefaultPricingPolicy is used when no pricing is configured
+var DefaultPricingPolicy = PricingPolicy{
+	TaxRate:		2000,
+	ShippingFee:	499,
+}
+
+// ApplyPricing calculates the subtotal, tax, shipping fee and total from the line items
+// all line items must have the same currency
+func (o *Order) ApplyPricing(policy PricingPolicy) error {
+	if len(o.Items) == 0 {
+		return ErrInvalidOperation
+	}
+
+	currency := o.Items[0].Total.Currency
+	lineTotals := make([]money.Money, 0, len(o.Items))
+	for _, item := range o.Items {
+		lineTotals = append(lineTotals, item.Total)
+	}
+
+	subtotal, err := money.Sum(currency, lineTotals...)
+	if err != nil {
+		return err
+	}
+
+	shippingFee := money.New(policy.ShippingFee, currency)
+	if policy.FreeShippingThreshold > 0 && subtotal.Amount >= policy.FreeShippingThreshold {
+		shippingFee = money.Zero(currency)
+	}
+
+	tax := subtotal.MulRatio(policy.TaxRate, 10000)
+
+	total, err := money.Sum(currency, subtotal, tax, shippingFee)
+	if err != nil {
+		return err
+	}
+
+	o.Subtotal = subtotal
+	o.Tax = tax
+	o.ShippingFee = shippingFee
+	o.Total = total
+	return nil
+}
+
 // Repository provides access to an order store
 type Repository interface {
 	Create(order *Order) (*Order, error)
-	UpdateStatus(id OrderId, newStatus OrderStatus) (*Order, error)
+	// changes the status if the transition is allowed and adds the change to the history of the order
+	UpdateStatus(id OrderId, change *StatusChange) (*Order, error)
 	Find(id OrderId) (*Order, error)
 	FindAll() []*Order
 	FindAllForUser(userId user.UserId) []*Order
+	// returns the page of the orders matching the query, newest first
+	Search(query Query) (*Page, error)
 }
 
 // ErrUnknown is used when an ordercould not be found.
@@ -77,6 +238,7 @@ var ErrUnknown = errors.New("Unknown order")
 
 // ErrInvalidOperation is returned when an illegal operation on the order model is being executed.
 // e.g. setting the OrderStatus to "Shipped" although the old status was not "Payment Successful"
+// the allowed status changes are defined in the transitions table
 var ErrInvalidOperation = errors.New("Invalid operation")
 
 // create random string for OrderIds
@@ -0,0 +1,167 @@
+package order
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/MICSTI/imsazon/models/user"
+)
+
+const (
+	// DefaultPageSize is used when the query does not specify a limit
+	DefaultPageSize = 20
+
+	// MaxPageSize is the maximum number of orders returned in one page
+	MaxPageSize = 100
+)
+
+// ParseStatus returns the OrderStatus for its number or its name, e.g. "3", "Shipped" or "payment successful"
+func ParseStatus(s string) (OrderStatus, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if status := OrderStatus(n); status.Valid() {
+			return status, nil
+		}
+		return 0, ErrInvalidQuery
+	}
+
+	normalized := strings.ToLower(strings.Replace(s, " ", "", -1))
+	for status := range transitions {
+		if strings.ToLower(strings.Replace(status.String(), " ", "", -1)) == normalized {
+			return status, nil
+		}
+	}
+	return 0, ErrInvalidQuery
+}
+
+// Query describes which orders are listed, the results are always sorted newest first
+// zero values do not restrict the search
+type Query struct {
+	UserId			user.UserId
+	// only returns orders created at or after From and before To
+	From			time.Time
+	To				time.Time
+	// only returns orders with one of these statuses
+	Statuses		[]OrderStatus
+
+	// the NextCursor of the previous page, empty for the first page
+	Cursor			string
+	Limit			int
+}
+
+// Page is one page of orders
+type Page struct {
+	Orders			[]*Order		`json:"orders"`
+	// the number of orders matching the query on all pages
+	Total			int				`json:"total"`
+	// pass this as cursor to get the next page, empty if this is the last page
+	NextCursor		string			`json:"nextCursor,omitempty"`
+}
+
+// the cursor contains the sort keys of the last order on a page
+type cursor struct {
+	Id				OrderId			`json:"id"`
+	CreatedAt		time.Time		`json:"createdAt"`
+}
+
+func encodeCursor(o *Order) string {
+	data, _ := json.Marshal(cursor{Id: o.Id, CreatedAt: o.CreatedAt})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*Order, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &Order{Id: c.Id, CreatedAt: c.CreatedAt}, nil
+}
+
+// Matches returns true if the order passes all filters of the query
+func (q Query) Matches(o *Order) bool {
+	if q.UserId != "" && o.UserId != q.UserId {
+		return false
+	}
+
+	if !q.From.IsZero() && o.CreatedAt.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !o.CreatedAt.Before(q.To) {
+		return false
+	}
+
+	if len(q.Statuses) > 0 {
+		for _, status := range q.Statuses {
+			if o.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// returns true if a has to be listed before b - newer orders come first, the id makes the order unique
+func less(a *Order, b *Order) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.Id > b.Id
+}
+
+// Paginate sorts the orders matching the query and returns the page selected by its cursor and limit
+// the repositories use it after filtering the stored orders with Matches
+func (q Query) Paginate(matching []*Order) (*Page, error) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return less(matching[i], matching[j])
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		last, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip everything up to and including the last order of the previous page
+		start = sort.Search(len(matching), func(i int) bool {
+			return less(last, matching[i])
+		})
+	}
+
+	end := start + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	page := &Page{
+		Orders:		matching[start:end],
+		Total:		len(matching),
+	}
+
+	if end < len(matching) {
+		page.NextCursor = encodeCursor(matching[end - 1])
+	}
+
+	return page, nil
+}
+
+// ErrInvalidQuery is returned when the search parameters are not valid
+var ErrInvalidQuery = errors.New("Invalid order query")
+
+// ErrInvalidCursor is returned when the pagination cursor could not be decoded
+var ErrInvalidCursor = errors.New("Invalid pagination cursor")
\ No newline at end of file
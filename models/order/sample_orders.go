@@ -1,6 +1,7 @@
 package order
 
 import (
+	"time"
 	"github.com/MICSTI/imsazon/models/product"
 	"github.com/MICSTI/imsazon/models/user"
 )
@@ -13,32 +14,40 @@ var (
 
 // sample orders
 var (
-	Order1 = &Order{
+	Order1 = newSampleOrder(&Order{
 		Id:	O0001,
-		Date: "18.01.2018",
 		UserId: user.U0001,
-		Items: []*product.SimpleProduct{
-			&product.SimpleProduct{
-				Id: product.P0001,
-				Quantity: 2,
-			},
-			&product.SimpleProduct{
-				Id: product.P0003,
-				Quantity: 1,
-			},
+		Items: []*LineItem{
+			NewLineItem(product.Lightsaber, 2),
+			NewLineItem(product.BB8, 1),
 		},
 		Status: Shipped,
-	}
-	Order2 = &Order{
+		History: sampleHistory(time.Date(2018, 1, 18, 10, 0, 0, 0, time.UTC), user.U0001, Created, PaymentSuccessful, Shipped),
+	})
+	Order2 = newSampleOrder(&Order{
 		Id: O0002,
-		Date: "21.01.2018",
 		UserId: user.U0003,
-		Items: []*product.SimpleProduct{
-			&product.SimpleProduct{
-				Id: product.P0002,
-				Quantity: 1,
-			},
+		Items: []*LineItem{
+			NewLineItem(product.MilleniumFalcon, 1),
 		},
 		Status: Returned,
+		History: sampleHistory(time.Date(2018, 1, 21, 10, 0, 0, 0, time.UTC), user.U0003, Created, PaymentSuccessful, Shipped, ReturnRequested, Returned),
+	})
+)
+
+// prices the sample order with the default pricing policy and takes its timestamps from the history
+func newSampleOrder(o *Order) *Order {
+	o.ApplyPricing(DefaultPricingPolicy)
+	o.CreatedAt = o.History[0].ChangedAt
+	o.UpdatedAt = o.History[len(o.History) - 1].ChangedAt
+	return o
+}
+
+// creates a history with one status change per day, all made by the passed user
+func sampleHistory(start time.Time, actor user.UserId, statuses ...OrderStatus) []*StatusChange {
+	history := make([]*StatusChange, 0, len(statuses))
+	for i, status := range statuses {
+		history = append(history, &StatusChange{Status: status, ChangedAt: start.AddDate(0, 0, i), Actor: actor.String()})
 	}
-)
\ No newline at end of file
+	return history
+}
\ No newline at end of file
@@ -0,0 +1,273 @@
+// This package contains the model for payments and their lifecycle
+
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+	"github.com/MICSTI/imsazon/models/order"
+)
+
+// PaymentId uniquely identifies a payment
+type PaymentId string
+
+func (p PaymentId) String() string {
+	return string(p)
+}
+
+// PaymentStatus describes the status of a payment
+type PaymentStatus int
+
+// valid payment statuses
+const (
+	// the amount is reserved on the credit card, but not charged yet
+	Authorized	PaymentStatus = iota
+	Captured
+	PartiallyRefunded
+	Refunded
+	// the authorization has been cancelled without charging anything
+	Voided
+	// the authorization or charge has been declined
+	Failed
+	// the card holder has disputed the charge with their bank
+	Disputed
+)
+
+func (s PaymentStatus) String() string {
+	switch s {
+	case Authorized:
+		return "Authorized"
+	case Captured:
+		return "Captured"
+	case PartiallyRefunded:
+		return "Partially Refunded"
+	case Refunded:
+		return "Refunded"
+	case Voided:
+		return "Voided"
+	case Failed:
+		return "Failed"
+	case Disputed:
+		return "Disputed"
+	}
+	return "Unknown payment status"
+}
+
+// EventType is an operation that has been executed on a payment
+type EventType string
+
+const (
+	AuthorizeEvent	EventType = "authorize"
+	CaptureEvent	EventType = "capture"
+	VoidEvent		EventType = "void"
+	RefundEvent		EventType = "refund"
+	DisputeEvent	EventType = "dispute"
+)
+
+// Event is an entry in the history of a payment, failed operations are recorded as well
+type Event struct {
+	Type			EventType			`json:"type"`
+	Amount			money.Money			`json:"amount"`
+	At				time.Time			`json:"at"`
+	// empty if the operation was successful
+	Error			string				`json:"error,omitempty"`
+}
+
+type Payment struct {
+	Id				PaymentId			`json:"id"`
+	OrderId			order.OrderId		`json:"orderId"`
+	// the id the payment gateway uses for the payment
+	Reference		string				`json:"reference,omitempty"`
+	// only the last four digits of the card number are kept
+	CardLast4		string				`json:"cardLast4"`
+	Status			PaymentStatus		`json:"status"`
+	// the authorized amount
+	Amount			money.Money			`json:"amount"`
+	Captured		money.Money			`json:"captured"`
+	Refunded		money.Money			`json:"refunded"`
+	CreatedAt		time.Time			`json:"createdAt"`
+	UpdatedAt		time.Time			`json:"updatedAt"`
+	Events			[]*Event			`json:"events"`
+}
+
+// New creates an authorized payment, the reference is the id the payment gateway returned for the authorization
+func New(id PaymentId, orderId order.OrderId, reference string, cardNumber string, amount money.Money, now time.Time) *Payment {
+	return &Payment{
+		Id:				id,
+		OrderId:		orderId,
+		Reference:		reference,
+		CardLast4:		last4(cardNumber),
+		Status:			Authorized,
+		Amount:			amount,
+		Captured:		money.Zero(amount.Currency),
+		Refunded:		money.Zero(amount.Currency),
+		CreatedAt:		now,
+		UpdatedAt:		now,
+		Events:			[]*Event{{Type: AuthorizeEvent, Amount: amount, At: now}},
+	}
+}
+
+// NewFailed creates a payment whose authorization has been declined
+func NewFailed(id PaymentId, orderId order.OrderId, cardNumber string, amount money.Money, cause error, now time.Time) *Payment {
+	return &Payment{
+		Id:				id,
+		OrderId:		orderId,
+		CardLast4:		last4(cardNumber),
+		Status:			Failed,
+		Amount:			amount,
+		Captured:		money.Zero(amount.Currency),
+		Refunded:		money.Zero(amount.Currency),
+		CreatedAt:		now,
+		UpdatedAt:		now,
+		Events:			[]*Event{{Type: AuthorizeEvent, Amount: amount, At: now, Error: cause.Error()}},
+	}
+}
+
+func last4(cardNumber string) string {
+	if len(cardNumber) > 4 {
+		return cardNumber[len(cardNumber) - 4:]
+	}
+	return cardNumber
+}
+
+// Copy returns a copy of the payment that can be changed without changing the payment
+func (p *Payment) Copy() *Payment {
+	c := *p
+	c.Events = append([]*Event{}, p.Events...)
+	return &c
+}
+
+// Capture charges (a part of) the authorized amount, the rest of the authorization is released
+func (p *Payment) Capture(amount money.Money, now time.Time) error {
+	if p.Status != Authorized {
+		return ErrInvalidOperation
+	}
+
+	if err := checkAmount(amount, p.Amount); err != nil {
+		return err
+	}
+
+	p.Status = Captured
+	p.Captured = amount
+	p.record(&Event{Type: CaptureEvent, Amount: amount, At: now})
+	return nil
+}
+
+// Void cancels the authorization without charging anything
+func (p *Payment) Void(now time.Time) error {
+	if p.Status != Authorized {
+		return ErrInvalidOperation
+	}
+
+	p.Status = Voided
+	p.record(&Event{Type: VoidEvent, Amount: p.Amount, At: now})
+	return nil
+}
+
+// Refund pays back (a part of) the captured amount, a payment can be refunded several times until the whole amount has been paid back
+func (p *Payment) Refund(amount money.Money, now time.Time) error {
+	if p.Status != Captured && p.Status != PartiallyRefunded {
+		return ErrInvalidOperation
+	}
+
+	refundable, err := p.Captured.Sub(p.Refunded)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAmount(amount, refundable); err != nil {
+		return err
+	}
+
+	p.Refunded, _ = p.Refunded.Add(amount)
+
+	p.Status = PartiallyRefunded
+	if p.Refunded.Amount == p.Captured.Amount {
+		p.Status = Refunded
+	}
+
+	p.record(&Event{Type: RefundEvent, Amount: amount, At: now})
+	return nil
+}
+
+// Dispute marks a captured payment as disputed by the card holder, the amount is the disputed part of the payment
+func (p *Payment) Dispute(amount money.Money, now time.Time) error {
+	if p.Status != Captured && p.Status != PartiallyRefunded {
+		return ErrInvalidOperation
+	}
+
+	p.Status = Disputed
+	p.record(&Event{Type: DisputeEvent, Amount: amount, At: now})
+	return nil
+}
+
+// Fail records an operation that has been declined by the payment gateway, the status of the payment does not change
+func (p *Payment) Fail(eventType EventType, amount money.Money, cause error, now time.Time) {
+	p.record(&Event{Type: eventType, Amount: amount, At: now, Error: cause.Error()})
+}
+
+func (p *Payment) record(event *Event) {
+	p.Events = append(p.Events, event)
+	p.UpdatedAt = event.At
+}
+
+// the amount must be positive and must not exceed the limit
+func checkAmount(amount money.Money, limit money.Money) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+
+	c, err := amount.Cmp(limit)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return ErrInvalidAmount
+	}
+
+	return nil
+}
+
+// Active returns the latest payment of an order that has not failed or been voided, nil if there is none
+func Active(payments []*Payment) *Payment {
+	var active *Payment
+	for _, p := range payments {
+		if p.Status == Failed || p.Status == Voided {
+			continue
+		}
+		if active == nil || p.CreatedAt.After(active.CreatedAt) {
+			active = p
+		}
+	}
+	return active
+}
+
+// NextPaymentId returns a new random PaymentId
+func NextPaymentId() PaymentId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return PaymentId("PAY" + hex.EncodeToString(b))
+}
+
+// Repository provides access to a payment store
+type Repository interface {
+	// stores a new payment or replaces a stored one with the same id
+	Store(p *Payment) error
+	Find(id PaymentId) (*Payment, error)
+	// finds a payment by the id the payment gateway uses for it
+	FindByReference(reference string) (*Payment, error)
+	FindAllForOrder(orderId order.OrderId) []*Payment
+}
+
+// ErrUnknown is used when a payment could not be found
+var ErrUnknown = errors.New("Unknown payment")
+
+// ErrInvalidOperation is returned when an operation is not possible in the current status of the payment
+// e.g. capturing a payment that has been voided
+var ErrInvalidOperation = errors.New("Invalid operation")
+
+// ErrInvalidAmount is returned when an amount is not positive or exceeds the amount that can be captured or refunded
+var ErrInvalidAmount = errors.New("Invalid payment amount")
\ No newline at end of file
@@ -2,7 +2,13 @@
 
 package product
 
-import "errors"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+)
 
 // ProductId uniquely identifies a product
 type ProductId string
@@ -17,11 +23,14 @@ type Product struct {
 	Description		string			`json:"description"`
 	Category		string			`json:"category"`
 	ImageUrl		string			`json:"imageUrl"`
-	Price			float32			`json:"price"`
+	Price			money.Money		`json:"price"`
 	Quantity		int				`json:"quantity"`
+
+	// the quantity that is not held by active reservations, it is filled in by the repository and never stored
+	Available		int				`json:"available"`
 }
 
-func New(id ProductId, name string, description string, category string, imageUrl string, price float32, quantity int) *Product {
+func New(id ProductId, name string, description string, category string, imageUrl string, price money.Money, quantity int) *Product {
 	return &Product{
 		Id:				id,
 		Name:			name,
@@ -45,25 +54,75 @@ func NewSimpleProduct(id ProductId, quantity int) *SimpleProduct {
 	}
 }
 
+// MergeQuantities sums up the quantities of items with the same ProductId
+// the merged items keep the order in which their products first appeared
+func MergeQuantities(items []*SimpleProduct) []*SimpleProduct {
+	merged := []*SimpleProduct{}
+	idx := make(map[ProductId]int)
+	for _, item := range items {
+		if i, ok := idx[item.Id]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		idx[item.Id] = len(merged)
+		merged = append(merged, NewSimpleProduct(item.Id, item.Quantity))
+	}
+	return merged
+}
+
+// NextProductId returns a new random ProductId
+func NextProductId() ProductId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return ProductId("P" + hex.EncodeToString(b))
+}
+
 // Repository interface provides access to an in-memory product store
 type Repository interface {
 	// directly stores a product in the store
 	Store(product *Product) (*Product, error)
 
+	// updates the catalog data (name, description, category, image and price) of a product, the quantity stays untouched
+	// returns a new product object with the current stock status
+	Update(product *Product) (*Product, error)
+
+	// removes a product and all its reservations from the store
+	Remove(id ProductId) error
+
 	// tries to find a product in the store by ProductId
 	Find(id ProductId) (*Product, error)
 
 	// returns an array of all products inside the store
 	FindAll() []*Product
 
-	// adds a product to the store
-	// if the product id already exists, the name and description properties are updated and the quantity added
+	// returns the page of products matching the query
+	Search(query Query) (*Page, error)
+
+	// adds the quantity to the stock of an existing product
+	// returns a new product object with the current stock status
+	Add(item *SimpleProduct) (*Product, error)
+
+	// withdraws the quantity from the stock of a product, reserved items can't be withdrawn
 	// returns a new product object with the current stock status
-	Add(product *Product) (*Product, error)
+	Withdraw(item *SimpleProduct) (*Product, error)
 
-	// withdraws a product from the store
+	// withdraws several products from the store at once - either all of them or, if one is not available, none
+	// returns new product objects with the current stock status
+	WithdrawBatch(items []*SimpleProduct) ([]*Product, error)
+
+	// holds the quantity of a product until the reservation expires
+	// fails with ErrNotEnoughItems if less items are available
+	Reserve(reservation *Reservation) (*Reservation, error)
+
+	// withdraws the reserved items from the store and removes the reservation
 	// returns a new product object with the current stock status
-	Withdraw(product *Product) (*Product, error)
+	CommitReservation(id ReservationId) (*Product, error)
+
+	// removes the reservation without withdrawing anything
+	ReleaseReservation(id ReservationId) error
+
+	// removes all reservations that have expired at the passed time and returns them
+	ExpireReservations(now time.Time) ([]*Reservation, error)
 }
 
 var ErrProductUnknown = errors.New("Unknown product")
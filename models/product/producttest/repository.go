@@ -0,0 +1,179 @@
+// Package producttest contains the tests every product repository has to pass, so all backends behave the same
+package producttest
+
+import (
+	"sync"
+	"testing"
+	productModel "github.com/MICSTI/imsazon/models/product"
+)
+
+// TestRepository runs the tests against the repositories returned by newRepository
+// every test gets its own repository, which has to contain the sample products
+func TestRepository(t *testing.T, newRepository func(t *testing.T) productModel.Repository) {
+	t.Run("ConcurrentWithdraw", func(t *testing.T) { testConcurrentWithdraw(t, newRepository) })
+	t.Run("ConcurrentAddAndWithdraw", func(t *testing.T) { testConcurrentAddAndWithdraw(t, newRepository) })
+	t.Run("ConcurrentWithdrawBatch", func(t *testing.T) { testConcurrentWithdrawBatch(t, newRepository) })
+	t.Run("WithdrawBatchReportsFirstInvalidItem", func(t *testing.T) { testWithdrawBatchReportsFirstInvalidItem(t, newRepository) })
+}
+
+func testConcurrentWithdraw(t *testing.T, newRepository func(t *testing.T) productModel.Repository) {
+	r := newRepository(t)
+
+	start := quantityOf(t, r, productModel.P0001)
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	withdrawn := 0
+
+	for i := 0; i < start * 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := r.Withdraw(productModel.NewSimpleProduct(productModel.P0001, 1))
+			if err == productModel.ErrNotEnoughItems {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if p.Quantity < 0 {
+				t.Errorf("quantity is negative: %d", p.Quantity)
+			}
+			mtx.Lock()
+			withdrawn++
+			mtx.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if withdrawn != start {
+		t.Errorf("withdrawn %d items, want %d", withdrawn, start)
+	}
+
+	if q := quantityOf(t, r, productModel.P0001); q != 0 {
+		t.Errorf("quantity is %d, want 0", q)
+	}
+}
+
+func testConcurrentAddAndWithdraw(t *testing.T, newRepository func(t *testing.T) productModel.Repository) {
+	r := newRepository(t)
+
+	start := quantityOf(t, r, productModel.P0001)
+	const adds = 50
+	const withdrawals = 100
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	withdrawn := 0
+
+	for i := 0; i < adds; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Add(productModel.NewSimpleProduct(productModel.P0001, 1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	for i := 0; i < withdrawals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := r.Withdraw(productModel.NewSimpleProduct(productModel.P0001, 1))
+			if err == productModel.ErrNotEnoughItems {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if p.Quantity < 0 {
+				t.Errorf("quantity is negative: %d", p.Quantity)
+			}
+			mtx.Lock()
+			withdrawn++
+			mtx.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if q := quantityOf(t, r, productModel.P0001); q != start + adds - withdrawn || q < 0 {
+		t.Errorf("quantity is %d, want %d", q, start + adds - withdrawn)
+	}
+}
+
+func testConcurrentWithdrawBatch(t *testing.T, newRepository func(t *testing.T) productModel.Repository) {
+	r := newRepository(t)
+
+	startLightsaber := quantityOf(t, r, productModel.P0001)
+	startBB8 := quantityOf(t, r, productModel.P0003)
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	batches := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.WithdrawBatch([]*productModel.SimpleProduct{
+				productModel.NewSimpleProduct(productModel.P0001, 1),
+				productModel.NewSimpleProduct(productModel.P0003, 1),
+			})
+			if err == productModel.ErrNotEnoughItems {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mtx.Lock()
+			batches++
+			mtx.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	// BB8 runs out first, a failed batch must not withdraw any lightsabers
+	if batches != startBB8 {
+		t.Errorf("withdrew %d batches, want %d", batches, startBB8)
+	}
+
+	if q := quantityOf(t, r, productModel.P0001); q != startLightsaber - batches {
+		t.Errorf("lightsaber quantity is %d, want %d", q, startLightsaber - batches)
+	}
+
+	if q := quantityOf(t, r, productModel.P0003); q != 0 {
+		t.Errorf("BB8 quantity is %d, want 0", q)
+	}
+}
+
+func testWithdrawBatchReportsFirstInvalidItem(t *testing.T, newRepository func(t *testing.T) productModel.Repository) {
+	r := newRepository(t)
+
+	unknown := productModel.NewSimpleProduct("unknown", 1)
+	tooMany := productModel.NewSimpleProduct(productModel.P0001, 1000)
+
+	for i := 0; i < 20; i++ {
+		if _, err := r.WithdrawBatch([]*productModel.SimpleProduct{unknown, tooMany}); err != productModel.ErrProductUnknown {
+			t.Fatalf("got %v, want %v", err, productModel.ErrProductUnknown)
+		}
+
+		if _, err := r.WithdrawBatch([]*productModel.SimpleProduct{tooMany, unknown}); err != productModel.ErrNotEnoughItems {
+			t.Fatalf("got %v, want %v", err, productModel.ErrNotEnoughItems)
+		}
+	}
+}
+
+func quantityOf(t *testing.T, r productModel.Repository, id productModel.ProductId) int {
+	p, err := r.Find(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Quantity
+}
\ No newline at end of file
@@ -0,0 +1,284 @@
+package product
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+// SortField is the product property the search results are sorted by
+type SortField string
+
+const (
+	SortById		SortField = "id"
+	SortByName		SortField = "name"
+	SortByPrice		SortField = "price"
+)
+
+// ParseSortField returns the SortField for its name, an empty name sorts by id
+func ParseSortField(name string) (SortField, error) {
+	switch SortField(name) {
+	case "", SortById:
+		return SortById, nil
+	case SortByName:
+		return SortByName, nil
+	case SortByPrice:
+		return SortByPrice, nil
+	}
+	return "", ErrInvalidQuery
+}
+
+const (
+	// DefaultPageSize is used when the query does not specify a limit
+	DefaultPageSize = 20
+
+	// MaxPageSize is the maximum number of products returned in one page
+	MaxPageSize = 100
+)
+
+// Query describes which products are searched for and how the results are ordered and paginated
+// zero values do not restrict the search
+type Query struct {
+	// words that must all appear in the name or the description, case insensitive
+	Text			string
+	// only returns products in one of these categories
+	Categories		[]string
+	// prices in a different currency than the product price never match
+	MinPrice		money.Money
+	MaxPrice		money.Money
+	// only returns products with available items
+	InStock			bool
+
+	SortBy			SortField
+	Descending		bool
+
+	// the NextCursor of the previous page, empty for the first page
+	Cursor			string
+	Limit			int
+}
+
+// Page is one page of search results
+type Page struct {
+	Products		[]*Product		`json:"products"`
+	// the number of products matching the query on all pages
+	Total			int				`json:"total"`
+	// pass this as cursor to get the next page, empty if this is the last page
+	NextCursor		string			`json:"nextCursor,omitempty"`
+	Facets			*Facets			`json:"facets,omitempty"`
+}
+
+// PriceBucketLimits are the upper limits of the price buckets used for the facet counts
+// the last bucket contains all prices from the last limit upwards, prices in other currencies are not counted
+var PriceBucketLimits = []money.Money{
+	money.New(10000, money.DefaultCurrency),
+	money.New(100000, money.DefaultCurrency),
+	money.New(1000000, money.DefaultCurrency),
+}
+
+// Facets contains the number of products per category and price bucket
+// every facet ignores its own filter, so the counts show what selecting a different value would return
+type Facets struct {
+	Categories		map[string]int		`json:"categories"`
+	PriceBuckets	[]*PriceBucket		`json:"priceBuckets"`
+}
+
+// PriceBucket counts the products with a price from Min (inclusive) to Max (exclusive)
+type PriceBucket struct {
+	Min				money.Money		`json:"min"`
+	// nil for the last bucket, which has no upper limit
+	Max				*money.Money	`json:"max,omitempty"`
+	Count			int				`json:"count"`
+}
+
+// FacetCounter collects the facet counts of a query while the repository goes through its products
+type FacetCounter struct {
+	query			Query
+	facets			*Facets
+}
+
+// NewFacetCounter returns a counter for the facets of the query
+func (q Query) NewFacetCounter() *FacetCounter {
+	buckets := make([]*PriceBucket, 0, len(PriceBucketLimits) + 1)
+	min := money.Zero(money.DefaultCurrency)
+	for i := range PriceBucketLimits {
+		max := PriceBucketLimits[i]
+		buckets = append(buckets, &PriceBucket{Min: min, Max: &max})
+		min = max
+	}
+	buckets = append(buckets, &PriceBucket{Min: min})
+
+	return &FacetCounter{
+		query:	q,
+		facets:	&Facets{
+			Categories:		make(map[string]int),
+			PriceBuckets:	buckets,
+		},
+	}
+}
+
+// Count adds a product to the facet counts it belongs to
+func (c *FacetCounter) Count(p *Product) {
+	withoutCategories := c.query
+	withoutCategories.Categories = nil
+	if withoutCategories.Matches(p) {
+		c.facets.Categories[p.Category]++
+	}
+
+	withoutPrice := c.query
+	withoutPrice.MinPrice, withoutPrice.MaxPrice = money.Money{}, money.Money{}
+	if withoutPrice.Matches(p) && p.Price.Currency == money.DefaultCurrency {
+		for _, b := range c.facets.PriceBuckets {
+			if p.Price.Amount >= b.Min.Amount && (b.Max == nil || p.Price.Amount < b.Max.Amount) {
+				b.Count++
+				break
+			}
+		}
+	}
+}
+
+// Facets returns the collected counts
+func (c *FacetCounter) Facets() *Facets {
+	return c.facets
+}
+
+// the cursor contains the sort keys of the last product on a page
+type cursor struct {
+	Id			ProductId		`json:"id"`
+	Name		string			`json:"name,omitempty"`
+	Price		money.Money		`json:"price"`
+}
+
+func encodeCursor(p *Product) string {
+	data, _ := json.Marshal(cursor{Id: p.Id, Name: p.Name, Price: p.Price})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*Product, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &Product{Id: c.Id, Name: c.Name, Price: c.Price}, nil
+}
+
+// Matches returns true if the product passes all filters of the query
+func (q Query) Matches(p *Product) bool {
+	if len(q.Categories) > 0 && !contains(q.Categories, p.Category) {
+		return false
+	}
+
+	if !q.MinPrice.IsZero() {
+		if c, err := p.Price.Cmp(q.MinPrice); err != nil || c < 0 {
+			return false
+		}
+	}
+
+	if !q.MaxPrice.IsZero() {
+		if c, err := p.Price.Cmp(q.MaxPrice); err != nil || c > 0 {
+			return false
+		}
+	}
+
+	if q.InStock && p.Available <= 0 {
+		return false
+	}
+
+	if q.Text != "" {
+		name := strings.ToLower(p.Name)
+		description := strings.ToLower(p.Description)
+		for _, word := range strings.Fields(strings.ToLower(q.Text)) {
+			if !strings.Contains(name, word) && !strings.Contains(description, word) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if a has to be listed before b, the id makes the order unique
+func (q Query) less(a *Product, b *Product) bool {
+	switch q.SortBy {
+	case SortByName:
+		an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+		if an != bn {
+			return (an < bn) != q.Descending
+		}
+	case SortByPrice:
+		// amounts in different currencies can't be compared, so they are grouped by currency
+		if a.Price.Currency != b.Price.Currency {
+			return (a.Price.Currency < b.Price.Currency) != q.Descending
+		}
+		if a.Price.Amount != b.Price.Amount {
+			return (a.Price.Amount < b.Price.Amount) != q.Descending
+		}
+	}
+	if a.Id != b.Id {
+		return (a.Id < b.Id) != q.Descending
+	}
+	return false
+}
+
+// Paginate sorts the products matching the query and returns the page selected by its cursor and limit
+// the repositories use it after filtering the stored products with Matches
+func (q Query) Paginate(matching []*Product) (*Page, error) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return q.less(matching[i], matching[j])
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		last, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip everything up to and including the last product of the previous page
+		start = sort.Search(len(matching), func(i int) bool {
+			return q.less(last, matching[i])
+		})
+	}
+
+	end := start + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	page := &Page{
+		Products:	matching[start:end],
+		Total:		len(matching),
+	}
+
+	if end < len(matching) {
+		page.NextCursor = encodeCursor(matching[end - 1])
+	}
+
+	return page, nil
+}
+
+// ErrInvalidQuery is returned when the search parameters are not valid
+var ErrInvalidQuery = errors.New("Invalid search query")
+
+// ErrInvalidCursor is returned when the pagination cursor could not be decoded
+var ErrInvalidCursor = errors.New("Invalid pagination cursor")
\ No newline at end of file
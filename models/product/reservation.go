@@ -0,0 +1,61 @@
+package product
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ReservationId uniquely identifies a stock reservation
+type ReservationId string
+
+func (r ReservationId) String() string {
+	return string(r)
+}
+
+// Reservation holds a quantity of a product for a limited time, so it can't be sold to anyone else in the meantime
+type Reservation struct {
+	Id				ReservationId		`json:"id"`
+	ProductId		ProductId			`json:"productId"`
+	Quantity		int					`json:"quantity"`
+	ExpiresAt		time.Time			`json:"expiresAt"`
+}
+
+func NewReservation(id ReservationId, productId ProductId, quantity int, expiresAt time.Time) *Reservation {
+	return &Reservation{
+		Id:				id,
+		ProductId:		productId,
+		Quantity:		quantity,
+		ExpiresAt:		expiresAt,
+	}
+}
+
+// Expired returns true if the reservation is no longer active at the passed time
+func (r *Reservation) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// NextReservationId returns a new random ReservationId
+func NextReservationId() ReservationId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return ReservationId("R" + hex.EncodeToString(b))
+}
+
+// ReservedQuantities sums up the quantities of all active reservations per product
+func ReservedQuantities(reservations []*Reservation, now time.Time) map[ProductId]int {
+	reserved := make(map[ProductId]int)
+	for _, r := range reservations {
+		if !r.Expired(now) {
+			reserved[r.ProductId] += r.Quantity
+		}
+	}
+	return reserved
+}
+
+// ErrReservationUnknown is returned when a reservation does not exist (anymore)
+var ErrReservationUnknown = errors.New("Unknown reservation")
+
+// ErrReservationExpired is returned when a reservation is committed after it has expired
+var ErrReservationExpired = errors.New("The reservation has expired")
\ No newline at end of file
@@ -1,5 +1,7 @@
 package product
 
+import "github.com/MICSTI/imsazon/models/money"
+
 // sample ProductIds
 var (
 	P0001 ProductId = "P0001"
@@ -11,53 +13,53 @@ var (
 
 // sample products
 var (
-	Lightsaber = &Product{
+	Lightsaber = New(
 		P0001,
 		"Lightsaber",
 		"The perfect lightsaber for every aspiring Jedi",
-		"Weapons",
+		"weapons",
 		"http://images.buystarwarstoys.com/products/9288/1-1/ahsoka-tano-toy-lightsaber.jpg",
-		999.99,
+		money.New(99999, money.EUR),
 		10,
-	}
+	)
 
-	MilleniumFalcon = &Product{
+	MilleniumFalcon = New(
 		P0002,
 		"The Millenium Falcon",
 		"The fastest ship in the entire gallaxy - finished the Kessel Run in less than 12 parsecs",
-		"Mobility",
+		"mobility",
 		"http://ksassets.timeincuk.net/wp/uploads/sites/54/2017/11/Millenium-Falcon.jpg",
-		30000.00,
+		money.New(3000000, money.EUR),
 		1,
-	}
+	)
 
-	BB8 = &Product{
+	BB8 = New(
 		P0003,
 		"BB 8",
 		"Extraordinarily helpful droid",
-		"Droids",
+		"droids",
 		"https://images.fun.com/products/34909/2-1-63328/star-wars-episode-7-rey-jakku-and-bb8-black-series-set.jpg",
-		12499,
+		money.New(1249900, money.EUR),
 		3,
-	}
+	)
 
-	Podracer = &Product{
+	Podracer = New(
 		P0004,
 		"Podracer",
 		"Lightning-fast podracer - nobody will be able to beat you",
-		"Mobility",
+		"mobility",
 		"https://images-na.ssl-images-amazon.com/images/I/41j3vMHSX0L._AA300_.jpg",
-		3499.00,
+		money.New(349900, money.EUR),
 		6,
-	}
+	)
 
-	CarboniteFreezer = &Product{
+	CarboniteFreezer = New(
 		P0005,
 		"Carbonite Freezer",
 		"Very useful in case you need to freeze someone in carbonite",
-		"Utilities",
+		"utilities",
 		"https://s-i.huffpost.com/gen/1359887/images/o-HAN-SOLO-CARBONITE-facebook.jpg",
-		39999.99,
+		money.New(3999999, money.EUR),
 		2,
-	}
+	)
 )
\ No newline at end of file
@@ -0,0 +1,137 @@
+package returns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+	"github.com/MICSTI/imsazon/models/order"
+	"github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/user"
+)
+
+// ReturnId uniquely identifies a return (RMA)
+type ReturnId string
+
+func (r ReturnId) String() string {
+	return string(r)
+}
+
+// ReturnStatus describes the status of a return
+type ReturnStatus int
+
+// valid return statuses
+const (
+	Requested	ReturnStatus = iota
+	Approved
+	Rejected
+	// the items have arrived, were put back into the stock and the refund was issued
+	Completed
+	// the items have arrived and the refund is being issued
+	Refunding
+	// the refund could not be issued, receiving the return can be retried
+	RefundFailed
+)
+
+func (s ReturnStatus) String() string {
+	switch s {
+	case Requested:
+		return "Requested"
+	case Approved:
+		return "Approved"
+	case Rejected:
+		return "Rejected"
+	case Completed:
+		return "Completed"
+	case Refunding:
+		return "Refunding"
+	case RefundFailed:
+		return "Refund Failed"
+	}
+	return "Unknown return status"
+}
+
+// the allowed status changes - every status maps to the statuses a return can move on to
+var transitions = map[ReturnStatus][]ReturnStatus{
+	Requested:		{Approved, Rejected},
+	Approved:		{Refunding},
+	Refunding:		{Completed, RefundFailed},
+	RefundFailed:	{Refunding},
+	Rejected:		{},
+	Completed:		{},
+}
+
+// CanTransitionTo returns true if a return with this status may be changed to the next status
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is an entry in the status history of a return
+type StatusChange struct {
+	Status			ReturnStatus			`json:"status"`
+	ChangedAt		time.Time				`json:"changedAt"`
+	// the id of the user that changed the status
+	Actor			string					`json:"actor"`
+	// e.g. why a return has been rejected
+	Reason			string					`json:"reason,omitempty"`
+}
+
+// Item is a line item of an order that is sent back
+type Item struct {
+	ProductId		product.ProductId		`json:"id"`
+	Quantity		int						`json:"quantity"`
+	Reason			string					`json:"reason"`
+}
+
+type Return struct {
+	Id				ReturnId				`json:"id"`
+	OrderId			order.OrderId			`json:"orderId"`
+	UserId			user.UserId				`json:"userId"`
+	Items			[]*Item					`json:"items"`
+	Status			ReturnStatus			`json:"status"`
+	// the amount that is paid back once the items have arrived
+	Refund			money.Money				`json:"refund"`
+	// all status changes, starting with the request
+	History			[]*StatusChange			`json:"history"`
+}
+
+// ChangeStatus moves the return to the status of the change and records the change in its history
+// returns ErrInvalidOperation if the transition is not allowed
+func (r *Return) ChangeStatus(change *StatusChange) error {
+	if !r.Status.CanTransitionTo(change.Status) {
+		return ErrInvalidOperation
+	}
+
+	r.Status = change.Status
+	r.History = append(r.History, change)
+	return nil
+}
+
+// NextReturnId returns a new random ReturnId
+func NextReturnId() ReturnId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return ReturnId("RMA" + hex.EncodeToString(b))
+}
+
+// Repository provides access to a return store
+type Repository interface {
+	Create(r *Return) (*Return, error)
+	// changes the status if the transition is allowed and adds the change to the history of the return
+	UpdateStatus(id ReturnId, change *StatusChange) (*Return, error)
+	Find(id ReturnId) (*Return, error)
+	FindAll() []*Return
+	FindAllForOrder(orderId order.OrderId) []*Return
+}
+
+// ErrUnknown is used when a return could not be found
+var ErrUnknown = errors.New("Unknown return")
+
+// ErrInvalidOperation is returned when the status of a return can't be changed to the requested one
+var ErrInvalidOperation = errors.New("Invalid operation")
\ No newline at end of file
@@ -0,0 +1,49 @@
+// This package contains the model for refresh tokens and revoked JWT auth tokens
+
+package token
+
+import (
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/user"
+)
+
+// RefreshToken can be exchanged once for a new JWT auth token
+// only the hash of the token is stored, so a leaked store does not allow taking over sessions
+type RefreshToken struct {
+	Hash			string
+	UserId			user.UserId
+	ExpiresAt		time.Time
+}
+
+func New(hash string, userId user.UserId, expiresAt time.Time) *RefreshToken {
+	return &RefreshToken{
+		Hash:			hash,
+		UserId:			userId,
+		ExpiresAt:		expiresAt,
+	}
+}
+
+// Expired returns true if the refresh token can't be used anymore
+func (t *RefreshToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// Repository interface provides access to the refresh token store and the revocation list
+type Repository interface {
+	// stores a refresh token
+	StoreRefreshToken(refreshToken *RefreshToken) error
+
+	// removes a refresh token from the store and returns it, so every refresh token can only be used once
+	TakeRefreshToken(hash string) (*RefreshToken, error)
+
+	// puts the id of a JWT auth token on the revocation list
+	// the entry is only needed until the JWT would have expired anyway
+	Revoke(jti string, expiresAt time.Time) error
+
+	// checks if the id of a JWT auth token is on the revocation list
+	IsRevoked(jti string) bool
+}
+
+// ErrUnknown is used if the refresh token cannot be found
+var ErrUnknown = errors.New("Unknown refresh token")
\ No newline at end of file
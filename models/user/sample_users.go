@@ -8,8 +8,9 @@ var (
 )
 
 // Sample users
+// the passwords are "rey123", "kylo123" and "luke123", hashed with the default bcrypt cost
 var (
-	Rey = &User{U0001, "Rey", "rey@jedi.com", "rey", "rey123", Standard}
-	Kylo = &User{U0002, "Kylo", "kylo@firstorder.com", "kylo", "kylo123", Standard}
-	Luke = &User{ U0003, "Luke", "luke@jedi.com", "luke", "luke123", Admin}
+	Rey = &User{U0001, "Rey", "rey@jedi.com", "rey", "$2a$10$eEl3Vbc6q8qcz1Jo1qyO3.cLbwqwwsavgbZ2bldOkvkcBp99NWBou", Standard}
+	Kylo = &User{U0002, "Kylo", "kylo@firstorder.com", "kylo", "$2a$10$KxSDflxe2md9nOSYo6jDi.2FuosuCeKv5hqxKwMbAa9p4dNo7WFeO", Standard}
+	Luke = &User{ U0003, "Luke", "luke@jedi.com", "luke", "$2a$10$2FxsLBVkQ6fNfBY.Qf3HTeTmYTJvFa8EVLqFKYIbp1fftssTT59Tu", Admin}
 )
\ No newline at end of file
@@ -2,7 +2,15 @@
 
 package user
 
-import "errors"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultPasswordCost is the bcrypt cost used for hashing passwords if nothing else is configured
+const DefaultPasswordCost = bcrypt.DefaultCost
 
 // UserId uniquely identifies a user
 type UserId string
@@ -16,27 +24,80 @@ type User struct {
 	Name			string
 	Email			string
 	Username		string
-	Password		string
+	PasswordHash	string
 	Role			UserRole
 }
 
 // New creates a new user
-func New(id UserId, name string, email string, username string, password string, role UserRole) *User {
+// the password has to be hashed with HashPassword before
+func New(id UserId, name string, email string, username string, passwordHash string, role UserRole) *User {
 	return &User{
 		Id:				id,
 		Name:			name,
 		Email:			email,
 		Username:		username,
-		Password:		password,
+		PasswordHash:	passwordHash,
 		Role:			role,
 	}
 }
 
+// Profile is the public representation of a user, without any credentials
+type Profile struct {
+	Id				UserId			`json:"id"`
+	Name			string			`json:"name"`
+	Email			string			`json:"email"`
+	Username		string			`json:"username"`
+	Role			string			`json:"role"`
+}
+
+// Profile returns the public representation of the user
+func (u *User) Profile() *Profile {
+	return &Profile{
+		Id:				u.Id,
+		Name:			u.Name,
+		Email:			u.Email,
+		Username:		u.Username,
+		Role:			u.Role.String(),
+	}
+}
+
+// NextUserId returns a new random UserId
+func NextUserId() UserId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return UserId("U" + hex.EncodeToString(b))
+}
+
+// HashPassword returns the bcrypt hash of the password using the passed cost
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares the password with the stored hash in constant time
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// NeedsRehash returns true if the stored hash was created with a lower cost than the passed one
+func (u *User) NeedsRehash(cost int) bool {
+	hashCost, err := bcrypt.Cost([]byte(u.PasswordHash))
+	return err != nil || hashCost < cost
+}
+
 // Repository interface provides access to an in-memory user store
 type Repository interface {
 	// adds a user to the store
+	// fails if the username or the email address is already used by another user
 	Add(user *User) error
 
+	// replaces the stored data of an existing user
+	// fails if the email address is already used by another user
+	Update(user *User) error
+
 	// attempts to find the user by id inside the store
 	Find(id UserId) (*User, error)
 
@@ -45,11 +106,20 @@ type Repository interface {
 
 	// checks if the login credentials match a user inside the store
 	CheckLogin(username string, password string) (*User, error)
+
+	// replaces the password hash of a user
+	UpdatePassword(id UserId, passwordHash string) error
 }
 
 // ErrUnknown is used if the user cannot be found
 var ErrUnknown = errors.New("Unknown user")
 
+// ErrUsernameTaken is used if another user already has the username
+var ErrUsernameTaken = errors.New("Username is already taken")
+
+// ErrEmailTaken is used if another user already has the email address
+var ErrEmailTaken = errors.New("Email address is already taken")
+
 // UserRole describes the role of the user
 type UserRole int
 
@@ -73,4 +143,18 @@ func (s UserRole) String() string {
 		return "Service"
 	}
 	return ""
+}
+
+// ParseUserRole returns the UserRole matching the passed name
+// unknown names are mapped to Nobody
+func ParseUserRole(name string) UserRole {
+	switch name {
+	case Standard.String():
+		return Standard
+	case Admin.String():
+		return Admin
+	case Service.String():
+		return Service
+	}
+	return Nobody
 }
\ No newline at end of file
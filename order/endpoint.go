@@ -5,6 +5,7 @@ import (
 	orderModel "github.com/MICSTI/imsazon/models/order"
 	"github.com/go-kit/kit/endpoint"
 	"context"
+	"github.com/MICSTI/imsazon/auth"
 )
 
 type createRequest struct {
@@ -21,6 +22,8 @@ func (r createResponse) error() error { return r.Err }
 func makeCreateEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(createRequest)
+		// orders are always created for the authenticated user
+		req.Order.UserId = auth.UserIdFromContext(ctx)
 		createdOrder, err := s.Create(req.Order)
 		return createResponse{Order: createdOrder, Err: err}, nil
 	}
@@ -41,11 +44,40 @@ func (r updateStatusResponse) error() error { return r.Err }
 func makeUpdateStatusEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(updateStatusRequest)
-		updatedOrder, err := s.UpdateStatus(req.Id, req.NewStatus)
+		// the authenticated user or service is recorded in the status history
+		updatedOrder, err := s.UpdateStatus(req.Id, req.NewStatus, auth.UserIdFromContext(ctx).String())
 		return updateStatusResponse{Order: updatedOrder, Err: err}, nil
 	}
 }
 
+type cancelRequest struct {
+	Id				orderModel.OrderId
+	Reason			string
+}
+
+type cancelResponse struct {
+	Order			*orderModel.Order		`json:"order,omitempty"`
+	Err				error					`json:"error,omitempty"`
+}
+
+func (r cancelResponse) error() error { return r.Err }
+
+func makeCancelEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(cancelRequest)
+		// users can only cancel their own orders
+		o, err := s.GetById(req.Id)
+		if err != nil {
+			return cancelResponse{Err: err}, nil
+		}
+		if !auth.CanAccess(ctx, o.UserId) {
+			return cancelResponse{Err: auth.ErrForbidden}, nil
+		}
+		cancelledOrder, err := s.Cancel(req.Id, req.Reason, auth.UserIdFromContext(ctx).String())
+		return cancelResponse{Order: cancelledOrder, Err: err}, nil
+	}
+}
+
 type getByIdRequest struct {
 	Id				orderModel.OrderId
 }
@@ -61,37 +93,55 @@ func makeGetByIdEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(getByIdRequest)
 		o, err := s.GetById(req.Id)
+		if err == nil && !auth.CanAccess(ctx, o.UserId) {
+			return getByIdResponse{Err: auth.ErrForbidden}, nil
+		}
 		return getByIdResponse{Order: o, Err: err}, nil
 	}
 }
 
 type getAllRequest struct {
-
+	Query			orderModel.Query
 }
 
 type getAllResponse struct {
 	Orders			[]*orderModel.Order		`json:"orders"`
+	Total			int						`json:"total"`
+	NextCursor		string					`json:"nextCursor,omitempty"`
+	Err				error					`json:"error,omitempty"`
 }
 
+func (r getAllResponse) error() error { return r.Err }
+
 func makeGetAllEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		orders := s.GetAll()
-		return getAllResponse{Orders: orders}, nil
+		req := request.(getAllRequest)
+		page, err := s.GetAll(req.Query)
+		return getAllResponse{Orders: page.Orders, Total: page.Total, NextCursor: page.NextCursor, Err: err}, nil
 	}
 }
 
 type getAllForUserRequest struct {
 	UserId			userModel.UserId
+	Query			orderModel.Query
 }
 
 type getAllForUserResponse struct {
 	Orders			[]*orderModel.Order		`json:"orders"`
+	Total			int						`json:"total"`
+	NextCursor		string					`json:"nextCursor,omitempty"`
+	Err				error					`json:"error,omitempty"`
 }
 
+func (r getAllForUserResponse) error() error { return r.Err }
+
 func makeGetAllForUserEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(getAllForUserRequest)
-		orders := s.GetAllForUser(req.UserId)
-		return getAllForUserResponse{Orders: orders}, nil
+		if !auth.CanAccess(ctx, req.UserId) {
+			return getAllForUserResponse{Err: auth.ErrForbidden}, nil
+		}
+		page, err := s.GetAllForUser(req.UserId, req.Query)
+		return getAllForUserResponse{Orders: page.Orders, Total: page.Total, NextCursor: page.NextCursor, Err: err}, nil
 	}
 }
\ No newline at end of file
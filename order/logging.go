@@ -30,17 +30,46 @@ func (s *loggingService) Create(newOrder *orderModel.Order) (order *orderModel.O
 	return s.Service.Create(newOrder)
 }
 
-func (s *loggingService) UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) (order *orderModel.Order, err error) {
+func (s *loggingService) UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "UpdateStatus",
 			"orderId", id,
 			"newStatus", newStatus.String(),
+			"actor", actor,
 			"took", time.Since(begin),
 			"err", err,
 		)
 	}(time.Now())
-	return s.Service.UpdateStatus(id, newStatus)
+	return s.Service.UpdateStatus(id, newStatus, actor)
+}
+
+func (s *loggingService) UpdateReturnStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "UpdateReturnStatus",
+			"orderId", id,
+			"newStatus", newStatus.String(),
+			"actor", actor,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.UpdateReturnStatus(id, newStatus, actor)
+}
+
+func (s *loggingService) Cancel(id orderModel.OrderId, reason string, actor string) (order *orderModel.Order, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Cancel",
+			"orderId", id,
+			"reason", reason,
+			"actor", actor,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Cancel(id, reason, actor)
 }
 
 func (s *loggingService) GetById(id orderModel.OrderId) (order *orderModel.Order, err error) {
@@ -55,23 +84,33 @@ func (s *loggingService) GetById(id orderModel.OrderId) (order *orderModel.Order
 	return s.Service.GetById(id)
 }
 
-func (s *loggingService) GetAll() []*orderModel.Order {
+func (s *loggingService) GetAll(query orderModel.Query) (page *orderModel.Page, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "GetAll",
+			"from", query.From,
+			"to", query.To,
+			"statuses", len(query.Statuses),
+			"limit", query.Limit,
 			"took", time.Since(begin),
+			"err", err,
 		)
 	}(time.Now())
-	return s.Service.GetAll()
+	return s.Service.GetAll(query)
 }
 
-func (s *loggingService) GetAllForUser(userId userModel.UserId) []*orderModel.Order {
+func (s *loggingService) GetAllForUser(userId userModel.UserId, query orderModel.Query) (page *orderModel.Page, err error) {
 	defer func(begin time.Time) {
 		s.logger.Log(
 			"method", "GetAllForUser",
 			"userId", userId,
+			"from", query.From,
+			"to", query.To,
+			"statuses", len(query.Statuses),
+			"limit", query.Limit,
 			"took", time.Since(begin),
+			"err", err,
 		)
 	}(time.Now())
-	return s.Service.GetAllForUser(userId)
+	return s.Service.GetAllForUser(userId, query)
 }
\ No newline at end of file
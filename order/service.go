@@ -1,60 +1,199 @@
 /**
 	The order service is responsible for storing all orders and the user they belong to.
+	When an order is created, the current product names and prices are copied into the order together with
+	the tax and shipping fee, so the order keeps its value even if the product prices change later on.
+	Orders can be cancelled as long as they have not been shipped - the payment of a paid order is released (or refunded
+	if it has already been captured), its items are put back into the stock and the user is informed about the cancellation by email.
  */
 package order
 
 import (
 	"errors"
+	"fmt"
+	"html"
 	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	productModel "github.com/MICSTI/imsazon/models/product"
 	"github.com/MICSTI/imsazon/models/user"
-	"sort"
+	"github.com/MICSTI/imsazon/mail"
+	"github.com/MICSTI/imsazon/payment"
+	"github.com/MICSTI/imsazon/stock"
 	"time"
 )
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
 var ErrInvalidArgument = errors.New("Invalid argument")
 
+// ErrRefundFailed is returned when an order has been cancelled, but its payment could not be refunded
+var ErrRefundFailed = errors.New("The order has been cancelled, but the payment could not be refunded")
+
 // Service is the interface that provides order methods
 type Service interface {
-	// creates a new order
+	// creates a new order, the prices of the items are taken from the product repository
+	// returns a ValidationError if items are unknown or not available in the requested quantity
 	Create(newOrder *orderModel.Order) (order *orderModel.Order, err error)
 
-	// updates the status of an order
-	UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) (order *orderModel.Order, err error)
+	// updates the status of an order, only the transitions defined in the order model are allowed
+	// the actor is the user or service making the change, it is recorded in the status history
+	// cancelled and returned orders have to be refunded and restocked, so those statuses are rejected with ErrInvalidOperation -
+	// they can only be reached through Cancel and the returns service
+	UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error)
+
+	// updates the status of an order during a return, only ReturnRequested, Returned and Shipped (for closing a return) are allowed
+	// it is meant to be used by the returns service, which takes care of the refund and the restocking
+	UpdateReturnStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error)
+
+	// cancels an order that has not been shipped yet
+	// if it has already been paid, the total is refunded and the items are put back into the stock
+	Cancel(id orderModel.OrderId, reason string, actor string) (order *orderModel.Order, err error)
 
 	// returns an order by id
 	GetById(id orderModel.OrderId) (*orderModel.Order, error)
 
-	// returns all orders
-	GetAll() []*orderModel.Order
+	// returns a page of the orders matching the query, newest first
+	GetAll(query orderModel.Query) (*orderModel.Page, error)
 
-	// returns all order for a specific user
-	GetAllForUser(userId user.UserId) []*orderModel.Order
+	// returns a page of the orders of a specific user matching the query, newest first
+	GetAllForUser(userId user.UserId, query orderModel.Query) (*orderModel.Page, error)
 }
 
 type service struct {
 	orders			orderModel.Repository
+	products		productModel.Repository
+	pricing			orderModel.PricingPolicy
+	users			user.Repository
+	stock			stock.Service
+	payments		payment.Service
+	mails			mail.Service
 }
 
 func (s *service) Create(newOrder *orderModel.Order) (order *orderModel.Order, err error) {
-	if newOrder.UserId == "" {
+	if newOrder.UserId == "" || len(newOrder.Items) == 0 {
 		return nil, ErrInvalidArgument
 	}
 
+	items, err := validateItems(newOrder.Items, s.products)
+
+	if err != nil {
+		return nil, err
+	}
+
+	newOrder.Items = items
+
+	if err := newOrder.ApplyPricing(s.pricing); err != nil {
+		return nil, err
+	}
+
 	newOrder.Id = orderModel.GetRandomOrderId()
 
-	// add today's date to order
-	newOrder.Date = time.Now().Format("02.01.2006")
+	now := time.Now()
+
+	newOrder.CreatedAt = now
+	newOrder.UpdatedAt = now
+
+	// the history starts with the creation of the order by its user
+	newOrder.Status = orderModel.Created
+	newOrder.History = []*orderModel.StatusChange{
+		{Status: orderModel.Created, ChangedAt: now, Actor: newOrder.UserId.String()},
+	}
 
 	return s.orders.Create(newOrder)
 }
 
-func (s *service) UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) (order *orderModel.Order, err error) {
-	if id == ""  {
+func (s *service) UpdateStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error) {
+	if id == "" || !newStatus.Valid() || actor == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	switch newStatus {
+	case orderModel.Cancelled, orderModel.ReturnRequested, orderModel.Returned:
+		return nil, orderModel.ErrInvalidOperation
+	}
+
+	return s.orders.UpdateStatus(id, &orderModel.StatusChange{Status: newStatus, ChangedAt: time.Now(), Actor: actor})
+}
+
+func (s *service) UpdateReturnStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus, actor string) (order *orderModel.Order, err error) {
+	if id == "" || !newStatus.Valid() || actor == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	switch newStatus {
+	case orderModel.ReturnRequested, orderModel.Returned, orderModel.Shipped:
+	default:
+		return nil, orderModel.ErrInvalidOperation
+	}
+
+	return s.orders.UpdateStatus(id, &orderModel.StatusChange{Status: newStatus, ChangedAt: time.Now(), Actor: actor})
+}
+
+func (s *service) Cancel(id orderModel.OrderId, reason string, actor string) (order *orderModel.Order, err error) {
+	if id == "" || actor == "" {
 		return nil, ErrInvalidArgument
 	}
 
-	return s.orders.UpdateStatus(id, newStatus)
+	// the repository only allows the cancellation if the order has not been shipped yet
+	cancelled, err := s.orders.UpdateStatus(id, &orderModel.StatusChange{
+		Status:		orderModel.Cancelled,
+		ChangedAt:	time.Now(),
+		Actor:		actor,
+		Reason:		reason,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the status before the cancellation is taken from the updated order, so it can't have changed in the meantime
+	previous := cancelled.History[len(cancelled.History) - 2].Status
+	paid := previous == orderModel.PaymentSuccessful
+
+	if paid {
+		// the items are only withdrawn from the stock after a successful payment
+		// products that have been removed from the catalog in the meantime can't be restocked
+		for _, item := range cancelled.Items {
+			s.stock.Add(productModel.NewSimpleProduct(item.ProductId, item.Quantity))
+		}
+
+		if err := s.releasePayment(cancelled.Id); err != nil {
+			return nil, ErrRefundFailed
+		}
+	}
+
+	// the order has already been cancelled at this point, so a mail that could not be sent must not fail the cancellation
+	if u, err := s.users.Find(cancelled.UserId); err == nil {
+		s.mails.Send(mail.New(u.Email, "Your order has been cancelled", cancellationMailBody(cancelled, reason, paid), "text/html"))
+	}
+
+	return cancelled, nil
+}
+
+// voids the authorized payment of an order, or refunds what is left of it if it has already been captured
+func (s *service) releasePayment(id orderModel.OrderId) error {
+	p := paymentModel.Active(s.payments.GetPaymentsForOrder(id))
+
+	// there is nothing to give back if the payment has already been voided at the payment provider
+	if p == nil {
+		return nil
+	}
+
+	if p.Status == paymentModel.Authorized {
+		_, err := s.payments.Void(p.Id)
+		return err
+	}
+
+	refundable, err := p.Captured.Sub(p.Refunded)
+
+	if err != nil {
+		return err
+	}
+
+	if !refundable.IsPositive() {
+		return nil
+	}
+
+	_, err = s.payments.Refund(p.Id, refundable)
+	return err
 }
 
 func (s *service) GetById(id orderModel.OrderId) (*orderModel.Order, error) {
@@ -65,35 +204,69 @@ func (s *service) GetById(id orderModel.OrderId) (*orderModel.Order, error) {
 	return s.orders.Find(id)
 }
 
-func (s *service) GetAll() []*orderModel.Order {
-	o := s.orders.FindAll()
+func (s *service) GetAll(query orderModel.Query) (*orderModel.Page, error) {
+	if query.Limit < 0 || query.Limit > orderModel.MaxPageSize {
+		return &orderModel.Page{}, ErrInvalidArgument
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return &orderModel.Page{}, ErrInvalidArgument
+	}
 
-	// sort orders by ID so always the same order will be returned
-	sort.Slice(o, func(i, j int) bool {
-		return o[i].Id < o[j].Id
-	})
+	for _, status := range query.Statuses {
+		if !status.Valid() {
+			return &orderModel.Page{}, ErrInvalidArgument
+		}
+	}
+
+	page, err := s.orders.Search(query)
 
-	return o
+	if err != nil {
+		return &orderModel.Page{}, err
+	}
+
+	return page, nil
 }
 
-func (s *service) GetAllForUser(userId user.UserId) []*orderModel.Order {
+func (s *service) GetAllForUser(userId user.UserId, query orderModel.Query) (*orderModel.Page, error) {
 	if userId == "" {
-		return nil
+		return &orderModel.Page{}, ErrInvalidArgument
 	}
 
-	o := s.orders.FindAllForUser(userId)
+	query.UserId = userId
 
-	// sort orders by ID so always the same order will be returned
-	sort.Slice(o, func(i, j int) bool {
-		return o[i].Id < o[j].Id
-	})
-
-	return o
+	return s.GetAll(query)
 }
 
 // NewService returns an order service with necessary dependencies.
-func NewService(orders orderModel.Repository) Service {
+func NewService(orders orderModel.Repository, products productModel.Repository, pricing orderModel.PricingPolicy, users user.Repository, stock stock.Service, payments payment.Service, mails mail.Service) Service {
 	return &service{
 		orders:		orders,
+		products:	products,
+		pricing:	pricing,
+		users:		users,
+		stock:		stock,
+		payments:	payments,
+		mails:		mails,
+	}
+}
+
+func cancellationMailBody(o *orderModel.Order, reason string, refunded bool) string {
+	body := `
+	<div style="font-size: 18pt; font-weight: bold; text-align: center; margin-bottom: 16px;">IMSazon</div>
+	<div style="font-size: 14pt; margin-bottom: 16px;">Your order ` + html.EscapeString(o.Id.String()) + ` has been cancelled.</div>`
+
+	if reason != "" {
+		body += `
+	<div style="font-size: 12pt; margin-bottom: 10px;">Reason: ` + html.EscapeString(reason) + `</div>`
+	}
+
+	if refunded {
+		body += fmt.Sprintf(`
+	<div style="font-size: 12pt; margin-bottom: 10px;">The amount of %s %s will be refunded to your credit card.</div>`, o.Total.Decimal(), o.Total.Currency)
 	}
+
+	return body + `
+	<div style="font-size: 12pt; margin-bottom: 10px;">- Michael from <b>IMSazon</b>
+`
 }
\ No newline at end of file
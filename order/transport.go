@@ -5,10 +5,15 @@ import (
 	kithttp "github.com/go-kit/kit/transport/http"
 	"encoding/json"
 	"net/http"
+	"io"
+	"strconv"
+	"time"
 	"context"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
 	userModel "github.com/MICSTI/imsazon/models/user"
 	productModel "github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/money"
 	orderModel "github.com/MICSTI/imsazon/models/order"
 	"errors"
 )
@@ -16,42 +21,53 @@ import (
 var ErrBadRoute = errors.New("Bad route")
 
 // MakeHandler returns a handler for the order service.
-func MakeHandler(ors Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(ors Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin, userModel.Service)
+
 	createHandler := kithttp.NewServer(
-		makeCreateEndpoint(ors),
+		authenticate(makeCreateEndpoint(ors)),
 		decodeCreateRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	updateStatusHandler := kithttp.NewServer(
-		makeUpdateStatusEndpoint(ors),
+		authenticate(authorize(makeUpdateStatusEndpoint(ors))),
 		decodeUpdateStatusRequest,
 		encodeResponse,
 		opts...,
 	)
 
+	cancelHandler := kithttp.NewServer(
+		authenticate(makeCancelEndpoint(ors)),
+		decodeCancelRequest,
+		encodeResponse,
+		opts...,
+	)
+
 	getByIdHandler := kithttp.NewServer(
-		makeGetByIdEndpoint(ors),
+		authenticate(makeGetByIdEndpoint(ors)),
 		decodeGetByIdRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	getAllHandler := kithttp.NewServer(
-		makeGetAllEndpoint(ors),
+		authenticate(authorize(makeGetAllEndpoint(ors))),
 		decodeGetAllRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	getAllForUserHandler := kithttp.NewServer(
-		makeGetAllForUserEndpoint(ors),
+		authenticate(makeGetAllForUserEndpoint(ors)),
 		decodeGetAllForUserRequest,
 		encodeResponse,
 		opts...,
@@ -61,6 +77,7 @@ func MakeHandler(ors Service, logger kitlog.Logger) http.Handler {
 
 	r.Handle("/order/create", createHandler).Methods("POST")
 	r.Handle("/order/update/{orderId}", updateStatusHandler).Methods("POST")
+	r.Handle("/order/cancel/{orderId}", cancelHandler).Methods("POST")
 	r.Handle("/order/single/{orderId}", getByIdHandler).Methods("GET")
 	r.Handle("/order/all", getAllHandler).Methods("GET")
 	r.Handle("/order/user/{userId}", getAllForUserHandler).Methods("GET")
@@ -70,7 +87,6 @@ func MakeHandler(ors Service, logger kitlog.Logger) http.Handler {
 
 func decodeCreateRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		UserId			userModel.UserId					`json:"userId"`
 		Items			[]*productModel.SimpleProduct		`json:"items"`
 	}
 
@@ -79,7 +95,7 @@ func decodeCreateRequest(_ context.Context, r *http.Request) (interface{}, error
 	}
 
 	return createRequest{
-		Order:		orderModel.New("", body.UserId, body.Items),
+		Order:		orderModel.New("", "", body.Items),
 	}, nil
 }
 
@@ -106,6 +122,30 @@ func decodeUpdateStatusRequest(_ context.Context, r *http.Request) (interface{},
 	}, nil
 }
 
+func decodeCancelRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["orderId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	// the reason is optional, so an empty body is fine as well
+	var body struct {
+		Reason			string						`json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return cancelRequest{
+		Id:			orderModel.OrderId(id),
+		Reason:		body.Reason,
+	}, nil
+}
+
 func decodeGetByIdRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	vars := mux.Vars(r)
 
@@ -121,7 +161,15 @@ func decodeGetByIdRequest(_ context.Context, r *http.Request) (interface{}, erro
 }
 
 func decodeGetAllRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	return getAllRequest{}, nil
+	query, err := decodeQuery(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getAllRequest{
+		Query:		query,
+	}, nil
 }
 
 func decodeGetAllForUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
@@ -133,11 +181,58 @@ func decodeGetAllForUserRequest(_ context.Context, r *http.Request) (interface{}
 		return nil, ErrBadRoute
 	}
 
+	query, err := decodeQuery(r)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return getAllForUserRequest{
 		UserId:		userModel.UserId(userId),
+		Query:		query,
 	}, nil
 }
 
+// reads the filter and pagination parameters from the query string, the times have to be in RFC 3339 format
+// e.g. /order/all?from=2018-01-01T00:00:00Z&to=2018-02-01T00:00:00Z&status=Shipped&status=Returned&limit=10
+func decodeQuery(r *http.Request) (orderModel.Query, error) {
+	params := r.URL.Query()
+
+	query := orderModel.Query{
+		Cursor:		params.Get("cursor"),
+	}
+
+	var err error
+
+	if v := params.Get("from"); v != "" {
+		if query.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, ErrInvalidArgument
+		}
+	}
+
+	if v := params.Get("to"); v != "" {
+		if query.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, ErrInvalidArgument
+		}
+	}
+
+	for _, v := range params["status"] {
+		status, err := orderModel.ParseStatus(v)
+		if err != nil {
+			return query, ErrInvalidArgument
+		}
+		query.Statuses = append(query.Statuses, status)
+	}
+
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			return query, ErrInvalidArgument
+		}
+	}
+
+	return query, nil
+}
+
 // encode the JSON response
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	if e, ok := response.(erroer); ok && e.error() != nil {
@@ -155,13 +250,40 @@ type erroer interface {
 // encode errors from business logic
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// invalid order items are reported line by line
+	if e, ok := err.(*ValidationError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+			"lines": e.Lines,
+		})
+		return
+	}
 	switch err {
 	case ErrInvalidArgument:
 		w.WriteHeader(http.StatusBadRequest)
 	case orderModel.ErrUnknown:
 		w.WriteHeader(http.StatusBadRequest)
+	case orderModel.ErrInvalidOperation:
+		w.WriteHeader(http.StatusConflict)
+	case orderModel.ErrInvalidCursor:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrRefundFailed:
+		w.WriteHeader(http.StatusBadGateway)
 	case ErrBadRoute:
 		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrProductUnknown:
+		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrCurrencyMismatch:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
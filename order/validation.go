@@ -0,0 +1,99 @@
+package order
+
+import (
+	"sort"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	productModel "github.com/MICSTI/imsazon/models/product"
+)
+
+// reasons why a line of a new order is not valid
+const (
+	ReasonMissingProduct	= "missingProduct"
+	ReasonInvalidQuantity	= "invalidQuantity"
+	ReasonUnknownProduct	= "unknownProduct"
+	ReasonNotEnoughItems	= "notEnoughItems"
+)
+
+// LineError describes why a line of a new order is not valid
+type LineError struct {
+	// the index of the line in the order, for merged duplicates the index of the first line
+	Line			int							`json:"line"`
+	ProductId		productModel.ProductId		`json:"productId"`
+	Reason			string						`json:"reason"`
+	// the number of items that can still be ordered, only set if there are not enough items
+	Available		*int						`json:"available,omitempty"`
+}
+
+// ValidationError is returned when one or more lines of a new order are not valid, it contains all invalid lines
+type ValidationError struct {
+	Lines			[]*LineError				`json:"lines"`
+}
+
+func (e *ValidationError) Error() string {
+	return "Invalid order items"
+}
+
+// checks the items of a new order against the product repository and takes a snapshot of the current product names and prices
+// lines with the same product are merged, the line items keep the order in which the products first appeared
+// a ValidationError listing every invalid line is returned if at least one of them is not valid
+func validateItems(items []*orderModel.LineItem, products productModel.Repository) ([]*orderModel.LineItem, error) {
+	lineErrors := []*LineError{}
+
+	merged := []*orderModel.LineItem{}
+	// the index of the first line for every merged item
+	lines := []int{}
+	mergedIdx := make(map[productModel.ProductId]int)
+
+	for i, item := range items {
+		if item == nil || item.ProductId == "" {
+			lineErrors = append(lineErrors, &LineError{Line: i, Reason: ReasonMissingProduct})
+			continue
+		}
+
+		if item.Quantity < 1 {
+			lineErrors = append(lineErrors, &LineError{Line: i, ProductId: item.ProductId, Reason: ReasonInvalidQuantity})
+			continue
+		}
+
+		if idx, ok := mergedIdx[item.ProductId]; ok {
+			merged[idx].Quantity += item.Quantity
+			continue
+		}
+
+		mergedIdx[item.ProductId] = len(merged)
+		merged = append(merged, &orderModel.LineItem{ProductId: item.ProductId, Quantity: item.Quantity})
+		lines = append(lines, i)
+	}
+
+	lineItems := make([]*orderModel.LineItem, 0, len(merged))
+
+	for idx, item := range merged {
+		p, err := products.Find(item.ProductId)
+
+		if err == productModel.ErrProductUnknown {
+			lineErrors = append(lineErrors, &LineError{Line: lines[idx], ProductId: item.ProductId, Reason: ReasonUnknownProduct})
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Quantity > p.Available {
+			available := p.Available
+			lineErrors = append(lineErrors, &LineError{Line: lines[idx], ProductId: item.ProductId, Reason: ReasonNotEnoughItems, Available: &available})
+			continue
+		}
+
+		lineItems = append(lineItems, orderModel.NewLineItem(p, item.Quantity))
+	}
+
+	if len(lineErrors) > 0 {
+		sort.SliceStable(lineErrors, func(i, j int) bool {
+			return lineErrors[i].Line < lineErrors[j].Line
+		})
+		return nil, &ValidationError{Lines: lineErrors}
+	}
+
+	return lineItems, nil
+}
\ No newline at end of file
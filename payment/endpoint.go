@@ -3,20 +3,21 @@ package payment
 import (
 	"github.com/go-kit/kit/endpoint"
 	"context"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
 )
 
 type chargeRequest struct {
 	Id					string
 	CardNumber			string
-	Amount				float32
-	Currency			string
+	Amount				money.Money
 }
 
 type chargeResponse struct {
 	Id					string						`json:"transactionId"`
 	CardNumber			string						`json:"creditCard"`
-	Amount				float32						`json:"amount"`
-	Currency			string						`json:"currency"`
+	Amount				money.Money					`json:"amount"`
 	Status				string						`json:"status"`
 	Err					error						`json:"error,omitempty"`
 }
@@ -31,7 +32,6 @@ func makeChargeEndpoint(s Service) endpoint.Endpoint {
 			Id:				req.Id,
 			CardNumber:		req.CardNumber,
 			Amount:			req.Amount,
-			Currency:		req.Currency,
 		}
 
 		status, err := s.Charge(creditCardCharge)
@@ -40,9 +40,92 @@ func makeChargeEndpoint(s Service) endpoint.Endpoint {
 			Id:				req.Id,
 			CardNumber:		req.CardNumber,
 			Amount:			req.Amount,
-			Currency:		req.Currency,
 			Status:			status.String(),
 			Err:			err,
 		}, nil
 	}
+}
+
+type authorizeResponse struct {
+	Payment				*paymentModel.Payment		`json:"payment,omitempty"`
+	Err					error						`json:"error,omitempty"`
+}
+
+func (r authorizeResponse) error() error { return r.Err }
+
+func makeAuthorizeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(chargeRequest)
+
+		p, err := s.Authorize(CreditCardCharge{
+			Id:				req.Id,
+			CardNumber:		req.CardNumber,
+			Amount:			req.Amount,
+		})
+
+		return authorizeResponse{Payment: p, Err: err}, nil
+	}
+}
+
+type paymentOperationRequest struct {
+	Id					paymentModel.PaymentId
+	Amount				money.Money
+}
+
+type paymentResponse struct {
+	Payment				*paymentModel.Payment		`json:"payment,omitempty"`
+	Err					error						`json:"error,omitempty"`
+}
+
+func (r paymentResponse) error() error { return r.Err }
+
+func makeCaptureEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(paymentOperationRequest)
+		p, err := s.Capture(req.Id, req.Amount)
+		return paymentResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeVoidEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(paymentOperationRequest)
+		p, err := s.Void(req.Id)
+		return paymentResponse{Payment: p, Err: err}, nil
+	}
+}
+
+func makeRefundEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(paymentOperationRequest)
+		p, err := s.Refund(req.Id, req.Amount)
+		return paymentResponse{Payment: p, Err: err}, nil
+	}
+}
+
+type getPaymentRequest struct {
+	Id					paymentModel.PaymentId
+}
+
+func makeGetPaymentEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getPaymentRequest)
+		p, err := s.GetPayment(req.Id)
+		return paymentResponse{Payment: p, Err: err}, nil
+	}
+}
+
+type getPaymentsForOrderRequest struct {
+	OrderId				orderModel.OrderId
+}
+
+type getPaymentsForOrderResponse struct {
+	Payments			[]*paymentModel.Payment		`json:"payments"`
+}
+
+func makeGetPaymentsForOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getPaymentsForOrderRequest)
+		return getPaymentsForOrderResponse{Payments: s.GetPaymentsForOrder(req.OrderId)}, nil
+	}
 }
\ No newline at end of file
@@ -0,0 +1,39 @@
+package payment
+
+import (
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+// Gateway is a payment provider that executes the charges and refunds of the payment service
+// the arguments have already been validated by the service
+type Gateway interface {
+	// charges the credit card and returns the id the provider uses for the charge, it is needed for refunds
+	Charge(charge CreditCardCharge) (reference string, status CreditCardChargeStatus, err error)
+
+	// reserves the amount on the credit card without charging it and returns the id the provider uses for the authorization
+	Authorize(charge CreditCardCharge) (reference string, status CreditCardChargeStatus, err error)
+
+	// charges (a part of) an authorized amount, identified by the reference the provider returned for the authorization
+	Capture(reference string, amount money.Money) error
+
+	// releases an authorization that has not been captured
+	Void(reference string) error
+
+	// pays back (a part of) a charge, identified by the reference the provider returned for it
+	Refund(reference string, amount money.Money) error
+}
+
+// returns the error that belongs to an unsuccessful charge status
+func errorFor(status CreditCardChargeStatus) error {
+	switch status {
+	case Success:
+		return nil
+	case CardError:
+		return ErrCard
+	case ValidationError:
+		return ErrValidation
+	case NetworkError:
+		return ErrNetwork
+	}
+	return ErrOther
+}
\ No newline at end of file
@@ -2,6 +2,9 @@ package payment
 
 import (
 	"github.com/go-kit/kit/log"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
 	"time"
 )
 
@@ -25,4 +28,104 @@ func (s *loggingService) Charge(charge CreditCardCharge) (status CreditCardCharg
 		)
 	}(time.Now())
 	return s.Service.Charge(charge)
+}
+
+func (s *loggingService) Authorize(charge CreditCardCharge) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Authorize",
+			"orderId", charge.Id,
+			"amount", charge.Amount.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Authorize(charge)
+}
+
+func (s *loggingService) Capture(id paymentModel.PaymentId, amount money.Money) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Capture",
+			"paymentId", id,
+			"amount", amount.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Capture(id, amount)
+}
+
+func (s *loggingService) Void(id paymentModel.PaymentId) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Void",
+			"paymentId", id,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Void(id)
+}
+
+func (s *loggingService) Refund(id paymentModel.PaymentId, amount money.Money) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Refund",
+			"paymentId", id,
+			"amount", amount.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Refund(id, amount)
+}
+
+func (s *loggingService) RecordRefund(reference string, total money.Money) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "RecordRefund",
+			"reference", reference,
+			"total", total.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.RecordRefund(reference, total)
+}
+
+func (s *loggingService) RecordDispute(reference string, amount money.Money) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "RecordDispute",
+			"reference", reference,
+			"amount", amount.String(),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.RecordDispute(reference, amount)
+}
+
+func (s *loggingService) GetPayment(id paymentModel.PaymentId) (p *paymentModel.Payment, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetPayment",
+			"paymentId", id,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.GetPayment(id)
+}
+
+func (s *loggingService) GetPaymentsForOrder(orderId orderModel.OrderId) []*paymentModel.Payment {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetPaymentsForOrder",
+			"orderId", orderId,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.GetPaymentsForOrder(orderId)
 }
\ No newline at end of file
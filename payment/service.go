@@ -1,17 +1,21 @@
 /*
 	The payment service is responsible for handling all payments.
 	Payments happens when a user started the checkout process, before items are shipped.
-
-				IMPORTANT NOTICE:
-	This is only a fake service, which obviously does not issue any real charges to credit cards.
-	It merely returns a success code in 80% of the cases and an error in the other 20% to simulate payment errors.
+	The amount of an order is only authorized at checkout - it is captured when the order ships, voided when the order
+	is cancelled before, and (partially) refunded when items are returned.
+	Every payment is stored with its history, failed operations included.
+	The operations are executed by a gateway - either a simulator, which does not issue any real charges,
+	or an adapter for a Stripe-compatible API.
  */
 package payment
 
 import (
 	"errors"
-	"math/rand"
+	"sync"
 	"time"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
 )
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
@@ -22,11 +26,11 @@ var ErrValidation = errors.New(ValidationError.String())
 var ErrNetwork = errors.New(NetworkError.String())
 var ErrOther = errors.New(OtherError.String())
 
+// CreditCardCharge is a request to charge or authorize an amount, its id is the id of the order it pays for
 type CreditCardCharge struct {
 	Id					string
 	CardNumber			string
-	Amount				float32
-	Currency			string
+	Amount				money.Money
 	Status				CreditCardChargeStatus
 }
 
@@ -59,35 +63,312 @@ func (s CreditCardChargeStatus) String() string {
 
 // Service is the interface that provides the payment methods
 type Service interface {
-	// Charge creates a new credit card charge.
+	// Charge creates a new credit card charge, the amount is captured immediately.
 	Charge(charge CreditCardCharge) (CreditCardChargeStatus, error)
+
+	// Authorize reserves the amount on the credit card, it is charged when the payment is captured.
+	// A declined authorization is stored as a failed payment and returned together with the error.
+	Authorize(charge CreditCardCharge) (*paymentModel.Payment, error)
+
+	// Capture charges (a part of) the authorized amount, a zero amount captures the whole amount.
+	Capture(id paymentModel.PaymentId, amount money.Money) (*paymentModel.Payment, error)
+
+	// Void releases an authorization that has not been captured.
+	Void(id paymentModel.PaymentId) (*paymentModel.Payment, error)
+
+	// Refund pays back (a part of) the captured amount of a payment.
+	Refund(id paymentModel.PaymentId, amount money.Money) (*paymentModel.Payment, error)
+
+	// RecordRefund records a refund that has been issued at the payment provider, e.g. reported by a webhook.
+	// The total is the amount that has been refunded so far, refunds that are already known are not recorded again.
+	// Refunding an authorization that has not been captured releases it, so the payment is voided then.
+	RecordRefund(reference string, total money.Money) (*paymentModel.Payment, error)
+
+	// RecordDispute marks the payment with the reference as disputed by the card holder.
+	RecordDispute(reference string, amount money.Money) (*paymentModel.Payment, error)
+
+	// GetPayment returns a payment by id.
+	GetPayment(id paymentModel.PaymentId) (*paymentModel.Payment, error)
+
+	// GetPaymentsForOrder returns all payments of an order, including the failed and voided ones.
+	GetPaymentsForOrder(orderId orderModel.OrderId) []*paymentModel.Payment
 }
 
 type service struct {
+	gateway			Gateway
+	payments		paymentModel.Repository
+	locks			*paymentLocks
+}
 
+func (s *service) Charge(charge CreditCardCharge) (CreditCardChargeStatus, error) {
+	_, status, err := s.create(charge, true)
+	return status, err
 }
 
-func (s *service) Charge(charge CreditCardCharge) (status CreditCardChargeStatus, err error) {
-	// create random source
-	randSource := rand.NewSource(time.Now().UnixNano())
-	randNumber := rand.New(randSource)
+func (s *service) Authorize(charge CreditCardCharge) (*paymentModel.Payment, error) {
+	p, _, err := s.create(charge, false)
+	return p, err
+}
+
+// authorizes the charge with the gateway and stores the new payment, captured payments are charged in the same step
+func (s *service) create(charge CreditCardCharge, capture bool) (*paymentModel.Payment, CreditCardChargeStatus, error) {
+	if charge.Id == "" || charge.CardNumber == "" || !charge.Amount.Currency.Valid() || !charge.Amount.IsPositive() {
+		return nil, ValidationError, ErrInvalidArgument
+	}
+
+	execute := s.gateway.Authorize
+	if capture {
+		execute = s.gateway.Charge
+	}
+
+	reference, status, err := execute(charge)
 
-	// in 80% of the cases we return a success status, in the other 20% an error
-	success := randNumber.Float64() < 0.8
+	now := time.Now()
+	orderId := orderModel.OrderId(charge.Id)
+	cardNumber := normalizeCardNumber(charge.CardNumber)
 
-	// add a fake delay of 1 second to make it more realistic
-	duration := time.Second
-	time.Sleep(duration)
+	if err != nil {
+		failed := paymentModel.NewFailed(paymentModel.NextPaymentId(), orderId, cardNumber, charge.Amount, err, now)
+		s.payments.Store(failed)
+		return failed, status, err
+	}
+
+	p := paymentModel.New(paymentModel.NextPaymentId(), orderId, reference, cardNumber, charge.Amount, now)
+	release := func() error { return s.gateway.Void(reference) }
+
+	if capture {
+		p.Capture(charge.Amount, now)
+		release = func() error { return s.gateway.Refund(reference, charge.Amount) }
+	}
 
-	if success {
-		return Success, nil
-	} else {
-		return CardError, ErrCard
+	// a payment that has not been stored can't be captured or refunded later, so the money is given back right away
+	if err := s.payments.Store(p); err != nil {
+		release()
+		return nil, OtherError, err
 	}
+
+	return p, Success, nil
 }
 
-func NewService() Service {
-	return &service{
+func (s *service) Capture(id paymentModel.PaymentId, amount money.Money) (*paymentModel.Payment, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
 
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	p, err := s.payments.Find(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if amount.IsZero() {
+		amount = p.Amount
+	}
+
+	return s.update(p, paymentModel.CaptureEvent, amount, func(updated *paymentModel.Payment, now time.Time) error {
+		return updated.Capture(amount, now)
+	}, func() error {
+		return s.gateway.Capture(p.Reference, amount)
+	})
+}
+
+func (s *service) Void(id paymentModel.PaymentId) (*paymentModel.Payment, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	p, err := s.payments.Find(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(p, paymentModel.VoidEvent, p.Amount, func(updated *paymentModel.Payment, now time.Time) error {
+		return updated.Void(now)
+	}, func() error {
+		return s.gateway.Void(p.Reference)
+	})
+}
+
+func (s *service) Refund(id paymentModel.PaymentId, amount money.Money) (*paymentModel.Payment, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	p, err := s.payments.Find(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(p, paymentModel.RefundEvent, amount, func(updated *paymentModel.Payment, now time.Time) error {
+		return updated.Refund(amount, now)
+	}, func() error {
+		return s.gateway.Refund(p.Reference, amount)
+	})
+}
+
+// applies an operation to a copy of the payment and only executes it with the gateway if it is allowed
+// if the gateway fails, the payment keeps its status and the failed attempt is added to its history
+func (s *service) update(p *paymentModel.Payment, eventType paymentModel.EventType, amount money.Money, apply func(*paymentModel.Payment, time.Time) error, execute func() error) (*paymentModel.Payment, error) {
+	now := time.Now()
+
+	updated := p.Copy()
+	if err := apply(updated, now); err != nil {
+		return nil, err
+	}
+
+	if err := execute(); err != nil {
+		failed := p.Copy()
+		failed.Fail(eventType, amount, err, now)
+		s.payments.Store(failed)
+		return nil, err
+	}
+
+	if err := s.payments.Store(updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (s *service) RecordRefund(reference string, total money.Money) (*paymentModel.Payment, error) {
+	if reference == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	return s.record(reference, func(p *paymentModel.Payment, now time.Time) error {
+		switch p.Status {
+		case paymentModel.Authorized:
+			return p.Void(now)
+		case paymentModel.Voided:
+			return nil
+		}
+
+		amount, err := total.Sub(p.Refunded)
+
+		if err != nil {
+			return err
+		}
+
+		// refunds issued by this service have already been recorded
+		if !amount.IsPositive() {
+			return nil
+		}
+
+		return p.Refund(amount, now)
+	})
+}
+
+func (s *service) RecordDispute(reference string, amount money.Money) (*paymentModel.Payment, error) {
+	if reference == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	return s.record(reference, func(p *paymentModel.Payment, now time.Time) error {
+		return p.Dispute(amount, now)
+	})
+}
+
+// applies a change that has already happened at the payment provider to the payment with the reference
+func (s *service) record(reference string, apply func(*paymentModel.Payment, time.Time) error) (*paymentModel.Payment, error) {
+	found, err := s.payments.FindByReference(reference)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.lock(found.Id)
+	defer unlock()
+
+	// the payment is read again, it may have changed while waiting for the lock
+	p, err := s.payments.Find(found.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated := p.Copy()
+	if err := apply(updated, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.payments.Store(updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (s *service) GetPayment(id paymentModel.PaymentId) (*paymentModel.Payment, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	return s.payments.Find(id)
+}
+
+func (s *service) GetPaymentsForOrder(orderId orderModel.OrderId) []*paymentModel.Payment {
+	if orderId == "" {
+		return nil
+	}
+
+	return s.payments.FindAllForOrder(orderId)
+}
+
+// makes sure that only one operation at a time is executed for a payment, so their status checks and gateway calls can't interleave
+type paymentLocks struct {
+	mtx				sync.Mutex
+	locks			map[paymentModel.PaymentId]*paymentLock
+}
+
+type paymentLock struct {
+	sync.Mutex
+	// the number of operations holding or waiting for the lock, it is removed when there are none left
+	users			int
+}
+
+// locks the payment and returns the function to unlock it again
+func (l *paymentLocks) lock(id paymentModel.PaymentId) func() {
+	l.mtx.Lock()
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &paymentLock{}
+		l.locks[id] = lock
+	}
+	lock.users++
+	l.mtx.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mtx.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(l.locks, id)
+		}
+		l.mtx.Unlock()
+	}
+}
+
+// NewService returns a payment service that executes the payments with the gateway and stores them in the repository
+func NewService(gateway Gateway, payments paymentModel.Repository) Service {
+	return &service{
+		gateway:		gateway,
+		payments:		payments,
+		locks:			&paymentLocks{
+			locks:		make(map[paymentModel.PaymentId]*paymentLock),
+		},
 	}
 }
\ No newline at end of file
@@ -0,0 +1,117 @@
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+// test card numbers that make the simulator return the respective status
+// all other card numbers with a valid check digit are charged successfully
+const (
+	TestCardSuccess			= "4242424242424242"
+	TestCardDeclined		= "4000000000000002"
+	TestCardInvalid			= "4000000000000127"
+	TestCardNetworkError	= "4000000000000119"
+	TestCardOtherError		= "4000000000000069"
+)
+
+var testCards = map[string]CreditCardChargeStatus{
+	TestCardSuccess:		Success,
+	TestCardDeclined:		CardError,
+	TestCardInvalid:		ValidationError,
+	TestCardNetworkError:	NetworkError,
+	TestCardOtherError:		OtherError,
+}
+
+// the simulator does not issue any real charges - the result only depends on the card number, so it can be used for development and testing
+type simulator struct {
+	latency			time.Duration
+}
+
+func (g *simulator) Charge(charge CreditCardCharge) (string, CreditCardChargeStatus, error) {
+	// every call takes the configured time to make it more realistic
+	time.Sleep(g.latency)
+
+	number := normalizeCardNumber(charge.CardNumber)
+
+	if !luhnValid(number) {
+		return "", ValidationError, ErrValidation
+	}
+
+	status, ok := testCards[number]
+	if !ok {
+		status = Success
+	}
+
+	if status != Success {
+		return "", status, errorFor(status)
+	}
+
+	// every call gets its own reference like a real charge, so retries for the same order can be told apart
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return "sim_" + charge.Id + "_" + hex.EncodeToString(b), Success, nil
+}
+
+// the simulator handles an authorization exactly like a charge
+func (g *simulator) Authorize(charge CreditCardCharge) (string, CreditCardChargeStatus, error) {
+	return g.Charge(charge)
+}
+
+func (g *simulator) Capture(reference string, amount money.Money) error {
+	time.Sleep(g.latency)
+	return nil
+}
+
+func (g *simulator) Void(reference string) error {
+	time.Sleep(g.latency)
+	return nil
+}
+
+func (g *simulator) Refund(reference string, amount money.Money) error {
+	time.Sleep(g.latency)
+	return nil
+}
+
+// removes the spaces and dashes that are often used to group the digits of card numbers
+func normalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// checks the check digit of a card number with the Luhn algorithm
+func luhnValid(number string) bool {
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		digit := int(number[len(number) - 1 - i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+
+		// every second digit from the right is doubled
+		if i % 2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum % 10 == 0
+}
+
+// NewSimulator returns a gateway that simulates a payment provider, every call takes as long as the latency
+func NewSimulator(latency time.Duration) Gateway {
+	return &simulator{
+		latency:		latency,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,81 @@
+package payment
+
+import (
+	"testing"
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number		string
+		valid		bool
+	}{
+		{TestCardSuccess, true},
+		{TestCardDeclined, true},
+		{"5555555555554444", true},
+		{"378282246310005", true},
+		{"4242424242424241", false},
+		{"4242424242424243", false},
+		{"4242424242", false},
+		{"42424242424242424242", false},
+		{"4242a24242424242", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if valid := luhnValid(test.number); valid != test.valid {
+			t.Errorf("luhnValid(%q) = %v, want %v", test.number, valid, test.valid)
+		}
+	}
+}
+
+func TestSimulatorCharge(t *testing.T) {
+	tests := []struct {
+		number		string
+		status		CreditCardChargeStatus
+		err			error
+	}{
+		{TestCardSuccess, Success, nil},
+		{"4242 4242 4242 4242", Success, nil},
+		{"4242-4242-4242-4242", Success, nil},
+		{"5555555555554444", Success, nil},
+		{TestCardDeclined, CardError, ErrCard},
+		{TestCardInvalid, ValidationError, ErrValidation},
+		{TestCardNetworkError, NetworkError, ErrNetwork},
+		{TestCardOtherError, OtherError, ErrOther},
+		{"4242424242424241", ValidationError, ErrValidation},
+	}
+
+	g := NewSimulator(0)
+
+	for _, test := range tests {
+		charge := CreditCardCharge{Id: "O0001", CardNumber: test.number, Amount: money.New(1000, money.EUR)}
+
+		reference, status, err := g.Charge(charge)
+
+		if status != test.status || err != test.err {
+			t.Errorf("Charge(%q) = %v, %v, want %v, %v", test.number, status, err, test.status, test.err)
+		}
+
+		if (status == Success) != (reference != "") {
+			t.Errorf("Charge(%q) returned reference %q with status %v", test.number, reference, status)
+		}
+	}
+}
+
+func TestSimulatorReferencesAreUnique(t *testing.T) {
+	g := NewSimulator(0)
+	charge := CreditCardCharge{Id: "O0001", CardNumber: TestCardSuccess, Amount: money.New(1000, money.EUR)}
+
+	references := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		reference, _, err := g.Authorize(charge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if references[reference] {
+			t.Fatalf("reference %q has been returned twice", reference)
+		}
+		references[reference] = true
+	}
+}
\ No newline at end of file
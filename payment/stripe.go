@@ -0,0 +1,157 @@
+package payment
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+// DefaultStripeUrl is the base url of the Stripe API
+const DefaultStripeUrl = "https://api.stripe.com"
+
+// the gateway talks to an API that is compatible with the charges and refunds endpoints of Stripe
+// the base url can point to a local fake server for testing
+type stripeGateway struct {
+	baseUrl			string
+	apiKey			string
+	client			*http.Client
+}
+
+func (g *stripeGateway) Charge(charge CreditCardCharge) (string, CreditCardChargeStatus, error) {
+	return g.createCharge(charge, true)
+}
+
+// an authorization is a charge that is not captured immediately
+func (g *stripeGateway) Authorize(charge CreditCardCharge) (string, CreditCardChargeStatus, error) {
+	return g.createCharge(charge, false)
+}
+
+func (g *stripeGateway) createCharge(charge CreditCardCharge, capture bool) (string, CreditCardChargeStatus, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(charge.Amount.Amount, 10))
+	form.Set("currency", strings.ToLower(charge.Amount.Currency.String()))
+	form.Set("card[number]", normalizeCardNumber(charge.CardNumber))
+	form.Set("metadata[charge_id]", charge.Id)
+	form.Set("capture", strconv.FormatBool(capture))
+
+	var result struct {
+		Id			string		`json:"id"`
+		Status		string		`json:"status"`
+	}
+
+	if status, err := g.post("/v1/charges", form, &result); err != nil {
+		return "", status, err
+	}
+
+	// a charge can be created and fail nevertheless
+	if result.Status == "failed" {
+		return result.Id, CardError, ErrCard
+	}
+
+	return result.Id, Success, nil
+}
+
+func (g *stripeGateway) Capture(reference string, amount money.Money) error {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+
+	var result struct {
+		Id			string		`json:"id"`
+	}
+
+	_, err := g.post("/v1/charges/" + url.PathEscape(reference) + "/capture", form, &result)
+	return err
+}
+
+// refunding an uncaptured charge without an amount releases the whole authorization
+func (g *stripeGateway) Void(reference string) error {
+	form := url.Values{}
+	form.Set("charge", reference)
+
+	var result struct {
+		Id			string		`json:"id"`
+	}
+
+	_, err := g.post("/v1/refunds", form, &result)
+	return err
+}
+
+func (g *stripeGateway) Refund(reference string, amount money.Money) error {
+	form := url.Values{}
+	form.Set("charge", reference)
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+
+	var result struct {
+		Id			string		`json:"id"`
+	}
+
+	_, err := g.post("/v1/refunds", form, &result)
+	return err
+}
+
+// sends a form encoded request and decodes the JSON response into v
+// for error responses the charge status and error that belong to the Stripe error are returned
+func (g *stripeGateway) post(path string, form url.Values, v interface{}) (CreditCardChargeStatus, error) {
+	req, err := http.NewRequest("POST", strings.TrimRight(g.baseUrl, "/") + path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OtherError, ErrOther
+	}
+
+	req.Header.Set("Authorization", "Bearer " + g.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return NetworkError, ErrNetwork
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return OtherError, ErrOther
+		}
+		return Success, nil
+	}
+
+	var body struct {
+		Error		struct {
+			Type		string		`json:"type"`
+		}							`json:"error"`
+	}
+
+	// the status code alone is enough to classify the error, so a body that can't be decoded is ignored
+	json.NewDecoder(resp.Body).Decode(&body)
+
+	status := statusForStripeError(resp.StatusCode, body.Error.Type)
+	return status, errorFor(status)
+}
+
+// maps the HTTP status code and the error type of a Stripe error response to a charge status
+func statusForStripeError(statusCode int, errorType string) CreditCardChargeStatus {
+	switch {
+	case errorType == "card_error" || statusCode == http.StatusPaymentRequired:
+		return CardError
+	case errorType == "api_error" || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return NetworkError
+	case errorType == "invalid_request_error" || statusCode == http.StatusBadRequest:
+		return ValidationError
+	}
+	return OtherError
+}
+
+// NewStripeGateway returns a gateway for a Stripe-compatible API at the base url, authenticated with the secret API key
+// http.DefaultClient is used if the client is nil
+func NewStripeGateway(baseUrl string, apiKey string, client *http.Client) Gateway {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &stripeGateway{
+		baseUrl:		baseUrl,
+		apiKey:			apiKey,
+		client:			client,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,107 @@
+package payment
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/MICSTI/imsazon/models/money"
+)
+
+func TestStripeGatewayCharge(t *testing.T) {
+	tests := []struct {
+		name			string
+		statusCode		int
+		body			string
+		reference		string
+		status			CreditCardChargeStatus
+		err				error
+	}{
+		{"succeeded", http.StatusOK, `{"id": "ch_1", "status": "succeeded"}`, "ch_1", Success, nil},
+		{"failed", http.StatusOK, `{"id": "ch_2", "status": "failed"}`, "ch_2", CardError, ErrCard},
+		{"card error", http.StatusPaymentRequired, `{"error": {"type": "card_error", "code": "card_declined"}}`, "", CardError, ErrCard},
+		{"invalid request", http.StatusBadRequest, `{"error": {"type": "invalid_request_error"}}`, "", ValidationError, ErrValidation},
+		{"server error", http.StatusInternalServerError, `{"error": {"type": "api_error"}}`, "", NetworkError, ErrNetwork},
+		{"server error without body", http.StatusServiceUnavailable, ``, "", NetworkError, ErrNetwork},
+		{"undecodable body", http.StatusOK, `<html>`, "", OtherError, ErrOther},
+	}
+
+	for _, test := range tests {
+		var form map[string]string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			form = map[string]string{
+				"path":				r.URL.Path,
+				"authorization":	r.Header.Get("Authorization"),
+				"amount":			r.PostForm.Get("amount"),
+				"currency":			r.PostForm.Get("currency"),
+				"card":				r.PostForm.Get("card[number]"),
+				"capture":			r.PostForm.Get("capture"),
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(test.statusCode)
+			fmt.Fprint(w, test.body)
+		}))
+
+		g := NewStripeGateway(server.URL, "sk_test", nil)
+
+		reference, status, err := g.Charge(CreditCardCharge{Id: "O0001", CardNumber: "4242 4242 4242 4242", Amount: money.New(1999, money.EUR)})
+
+		server.Close()
+
+		if reference != test.reference || status != test.status || err != test.err {
+			t.Errorf("%s: Charge() = %q, %v, %v, want %q, %v, %v", test.name, reference, status, err, test.reference, test.status, test.err)
+		}
+
+		want := map[string]string{
+			"path":				"/v1/charges",
+			"authorization":	"Bearer sk_test",
+			"amount":			"1999",
+			"currency":			"eur",
+			"card":				"4242424242424242",
+			"capture":			"true",
+		}
+
+		for key, value := range want {
+			if form[key] != value {
+				t.Errorf("%s: %s = %q, want %q", test.name, key, form[key], value)
+			}
+		}
+	}
+}
+
+func TestStripeGatewayNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	g := NewStripeGateway(url, "sk_test", nil)
+
+	reference, status, err := g.Charge(CreditCardCharge{Id: "O0001", CardNumber: TestCardSuccess, Amount: money.New(1999, money.EUR)})
+
+	if reference != "" || status != NetworkError || err != ErrNetwork {
+		t.Errorf("Charge() = %q, %v, %v, want \"\", %v, %v", reference, status, err, NetworkError, ErrNetwork)
+	}
+}
+
+func TestStripeGatewayAuthorizeDoesNotCapture(t *testing.T) {
+	var capture string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capture = r.FormValue("capture")
+		fmt.Fprint(w, `{"id": "ch_1", "status": "succeeded"}`)
+	}))
+	defer server.Close()
+
+	g := NewStripeGateway(server.URL, "sk_test", nil)
+
+	if _, status, err := g.Authorize(CreditCardCharge{Id: "O0001", CardNumber: TestCardSuccess, Amount: money.New(1999, money.EUR)}); status != Success || err != nil {
+		t.Fatalf("Authorize() = %v, %v, want %v, nil", status, err, Success)
+	}
+
+	if capture != "false" {
+		t.Errorf("capture = %q, want %q", capture, "false")
+	}
+}
\ No newline at end of file
@@ -7,26 +7,85 @@ import (
 	"encoding/json"
 	"context"
 	"errors"
+	"io"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	userModel "github.com/MICSTI/imsazon/models/user"
 )
 
 // MakeHandler returns a handler for the payment service
-func MakeHandler(ps Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(ps Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin, userModel.Service)
+
 	chargeHandler := kithttp.NewServer(
-		makeChargeEndpoint(ps),
+		authenticate(makeChargeEndpoint(ps)),
+		decodeChargeRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	authorizeHandler := kithttp.NewServer(
+		authenticate(makeAuthorizeEndpoint(ps)),
 		decodeChargeRequest,
 		encodeResponse,
 		opts...,
 	)
 
+	// only admins and other services may take, release or give back money of existing payments
+	captureHandler := kithttp.NewServer(
+		authenticate(authorize(makeCaptureEndpoint(ps))),
+		decodePaymentOperationRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	voidHandler := kithttp.NewServer(
+		authenticate(authorize(makeVoidEndpoint(ps))),
+		decodePaymentOperationRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	refundHandler := kithttp.NewServer(
+		authenticate(authorize(makeRefundEndpoint(ps))),
+		decodePaymentOperationRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getPaymentHandler := kithttp.NewServer(
+		authenticate(authorize(makeGetPaymentEndpoint(ps))),
+		decodeGetPaymentRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getPaymentsForOrderHandler := kithttp.NewServer(
+		authenticate(authorize(makeGetPaymentsForOrderEndpoint(ps))),
+		decodeGetPaymentsForOrderRequest,
+		encodeResponse,
+		opts...,
+	)
+
 	r := mux.NewRouter()
 
 	r.Handle("/payment/charge", chargeHandler).Methods("POST")
+	r.Handle("/payment/authorize", authorizeHandler).Methods("POST")
+	r.Handle("/payment/capture/{paymentId}", captureHandler).Methods("POST")
+	r.Handle("/payment/void/{paymentId}", voidHandler).Methods("POST")
+	r.Handle("/payment/refund/{paymentId}", refundHandler).Methods("POST")
+	r.Handle("/payment/single/{paymentId}", getPaymentHandler).Methods("GET")
+	r.Handle("/payment/order/{orderId}", getPaymentsForOrderHandler).Methods("GET")
 
 	return r
 }
@@ -49,21 +108,87 @@ var errBadRoute = errors.New("Bad route")
 
 func decodeChargeRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		Id				string		`json:"transactionId"`
-		CardNumber		string		`json:"creditCard"`
-		Amount			float32		`json:"amount"`
-		Currency		string		`json:"currency"`
+		Id				string				`json:"transactionId"`
+		CardNumber		string				`json:"creditCard"`
+		Amount			json.RawMessage		`json:"amount"`
+		Currency		string				`json:"currency"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		return nil, err
 	}
 
+	// the amount used to be a plain number with the currency next to it, it is still accepted that way
+	currency := money.DefaultCurrency
+	if body.Currency != "" {
+		c, err := money.ParseCurrency(body.Currency)
+		if err != nil {
+			return nil, err
+		}
+		currency = c
+	}
+
+	amount, err := money.Decode(body.Amount, currency)
+	if err != nil {
+		return nil, err
+	}
+
 	return chargeRequest{
 		Id:					body.Id,
 		CardNumber:			body.CardNumber,
+		Amount:				amount,
+	}, nil
+}
+
+func decodePaymentOperationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["paymentId"]
+
+	if !ok {
+		return nil, errBadRoute
+	}
+
+	// the amount is optional for captures and not needed for voids, so an empty body is fine as well
+	var body struct {
+		Amount			money.Money			`json:"amount"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return paymentOperationRequest{
+		Id:					paymentModel.PaymentId(id),
 		Amount:				body.Amount,
-		Currency:			body.Currency,
+	}, nil
+}
+
+func decodeGetPaymentRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["paymentId"]
+
+	if !ok {
+		return nil, errBadRoute
+	}
+
+	return getPaymentRequest{
+		Id:					paymentModel.PaymentId(id),
+	}, nil
+}
+
+func decodeGetPaymentsForOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["orderId"]
+
+	if !ok {
+		return nil, errBadRoute
+	}
+
+	return getPaymentsForOrderRequest{
+		OrderId:			orderModel.OrderId(id),
 	}, nil
 }
 
@@ -73,6 +198,10 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	switch err {
 	case ErrInvalidArgument:
 		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrInvalidAmount:
+		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrUnknownCurrency:
+		w.WriteHeader(http.StatusBadRequest)
 	case ErrCard:
 		w.WriteHeader(http.StatusBadRequest)
 	case ErrValidation:
@@ -81,6 +210,24 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		w.WriteHeader(http.StatusInternalServerError)
 	case ErrOther:
 		w.WriteHeader(http.StatusBadRequest)
+	case money.ErrCurrencyMismatch:
+		w.WriteHeader(http.StatusBadRequest)
+	case paymentModel.ErrInvalidAmount:
+		w.WriteHeader(http.StatusBadRequest)
+	case paymentModel.ErrInvalidOperation:
+		w.WriteHeader(http.StatusConflict)
+	case paymentModel.ErrUnknown:
+		w.WriteHeader(http.StatusNotFound)
+	case errBadRoute:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
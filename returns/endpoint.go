@@ -0,0 +1,118 @@
+package returns
+
+import (
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	"github.com/go-kit/kit/endpoint"
+	"context"
+	"github.com/MICSTI/imsazon/auth"
+)
+
+type requestRequest struct {
+	OrderId			orderModel.OrderId
+	Items			[]*returnModel.Item
+}
+
+type returnResponse struct {
+	Return			*returnModel.Return		`json:"return,omitempty"`
+	Err				error					`json:"error,omitempty"`
+}
+
+func (r returnResponse) error() error { return r.Err }
+
+func makeRequestEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(requestRequest)
+		// returns are always requested by the authenticated user for their own orders
+		r, err := s.Request(req.OrderId, auth.UserIdFromContext(ctx), req.Items)
+		return returnResponse{Return: r, Err: err}, nil
+	}
+}
+
+type approveRequest struct {
+	Id				returnModel.ReturnId
+}
+
+func makeApproveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(approveRequest)
+		r, err := s.Approve(req.Id, auth.UserIdFromContext(ctx).String())
+		return returnResponse{Return: r, Err: err}, nil
+	}
+}
+
+type rejectRequest struct {
+	Id				returnModel.ReturnId
+	Reason			string
+}
+
+func makeRejectEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(rejectRequest)
+		r, err := s.Reject(req.Id, req.Reason, auth.UserIdFromContext(ctx).String())
+		return returnResponse{Return: r, Err: err}, nil
+	}
+}
+
+type receiveRequest struct {
+	Id				returnModel.ReturnId
+}
+
+func makeReceiveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(receiveRequest)
+		r, err := s.Receive(req.Id, auth.UserIdFromContext(ctx).String())
+		return returnResponse{Return: r, Err: err}, nil
+	}
+}
+
+type getByIdRequest struct {
+	Id				returnModel.ReturnId
+}
+
+func makeGetByIdEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getByIdRequest)
+		r, err := s.GetById(req.Id)
+		if err == nil && !auth.CanAccess(ctx, r.UserId) {
+			return returnResponse{Err: auth.ErrForbidden}, nil
+		}
+		return returnResponse{Return: r, Err: err}, nil
+	}
+}
+
+type getAllRequest struct {
+
+}
+
+type getAllResponse struct {
+	Returns			[]*returnModel.Return	`json:"returns"`
+	Err				error					`json:"error,omitempty"`
+}
+
+func (r getAllResponse) error() error { return r.Err }
+
+func makeGetAllEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		returns := s.GetAll()
+		return getAllResponse{Returns: returns}, nil
+	}
+}
+
+type getAllForOrderRequest struct {
+	OrderId			orderModel.OrderId
+}
+
+func makeGetAllForOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getAllForOrderRequest)
+		returns := s.GetAllForOrder(req.OrderId)
+		// all returns of an order belong to the user of the order
+		for _, r := range returns {
+			if !auth.CanAccess(ctx, r.UserId) {
+				return getAllResponse{Err: auth.ErrForbidden}, nil
+			}
+		}
+		return getAllResponse{Returns: returns}, nil
+	}
+}
\ No newline at end of file
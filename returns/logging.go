@@ -0,0 +1,106 @@
+package returns
+
+import (
+	"github.com/go-kit/kit/log"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	"time"
+)
+
+type loggingService struct {
+	logger		log.Logger
+	Service
+}
+
+// NewLoggingService returns an instance of a logging Service.
+func NewLoggingService(logger log.Logger, s Service) Service {
+	return &loggingService{logger, s}
+}
+
+func (s *loggingService) Request(orderId orderModel.OrderId, userId userModel.UserId, items []*returnModel.Item) (ret *returnModel.Return, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Request",
+			"orderId", orderId,
+			"userId", userId,
+			"items", len(items),
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Request(orderId, userId, items)
+}
+
+func (s *loggingService) Approve(id returnModel.ReturnId, actor string) (ret *returnModel.Return, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Approve",
+			"returnId", id,
+			"actor", actor,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Approve(id, actor)
+}
+
+func (s *loggingService) Reject(id returnModel.ReturnId, reason string, actor string) (ret *returnModel.Return, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Reject",
+			"returnId", id,
+			"reason", reason,
+			"actor", actor,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Reject(id, reason, actor)
+}
+
+func (s *loggingService) Receive(id returnModel.ReturnId, actor string) (ret *returnModel.Return, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "Receive",
+			"returnId", id,
+			"actor", actor,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Receive(id, actor)
+}
+
+func (s *loggingService) GetById(id returnModel.ReturnId) (ret *returnModel.Return, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetById",
+			"returnId", id,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.GetById(id)
+}
+
+func (s *loggingService) GetAll() []*returnModel.Return {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetAll",
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.GetAll()
+}
+
+func (s *loggingService) GetAllForOrder(orderId orderModel.OrderId) []*returnModel.Return {
+	defer func(begin time.Time) {
+		s.logger.Log(
+			"method", "GetAllForOrder",
+			"orderId", orderId,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return s.Service.GetAllForOrder(orderId)
+}
\ No newline at end of file
@@ -0,0 +1,365 @@
+/**
+	The returns service handles return requests (RMAs) for shipped orders.
+	Customers can send back single line items of an order within a configurable window after it has been shipped.
+	An admin approves or rejects the request - once the approved items have arrived, they are put back into the stock
+	and their price including the tax is refunded. The shipping fee is not refunded.
+	While a return is open, the order has the status "Return Requested", so there is at most one open return per order.
+	After a partial return the order goes back to "Shipped", when all items have been sent back it is "Returned".
+ */
+package returns
+
+import (
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	"github.com/MICSTI/imsazon/order"
+	"github.com/MICSTI/imsazon/payment"
+	"github.com/MICSTI/imsazon/stock"
+)
+
+// ErrInvalidArgument is returned when one or more arguments are invalid.
+var ErrInvalidArgument = errors.New("Invalid argument")
+
+// ErrNotReturnable is returned when a return is requested for an order that has not been shipped or already has an open return
+var ErrNotReturnable = errors.New("The order can't be returned")
+
+// ErrWindowExpired is returned when a return is requested too long after the order has been shipped
+var ErrWindowExpired = errors.New("The return window has expired")
+
+// ErrInvalidItem is returned when an item is not part of the order or more items are returned than have been ordered
+var ErrInvalidItem = errors.New("Invalid return item")
+
+// ErrRefundFailed is returned when the refund of a received return could not be issued, receiving it can be retried
+var ErrRefundFailed = errors.New("The refund could not be issued, the return has not been completed")
+
+// DefaultWindow is used when no return window is configured
+const DefaultWindow = time.Hour * 24 * 14
+
+// Service is the interface that provides the return methods
+type Service interface {
+	// requests the return of items of a shipped order, the user must be the owner of the order
+	Request(orderId orderModel.OrderId, userId userModel.UserId, items []*returnModel.Item) (*returnModel.Return, error)
+
+	// approves a requested return, the customer can send the items back afterwards
+	Approve(id returnModel.ReturnId, actor string) (*returnModel.Return, error)
+
+	// rejects a requested return, the order goes back to "Shipped"
+	Reject(id returnModel.ReturnId, reason string, actor string) (*returnModel.Return, error)
+
+	// completes an approved return when the items have arrived - they are put back into the stock and the refund is issued
+	// if the refund fails, the return is marked as "Refund Failed" and can be received again
+	Receive(id returnModel.ReturnId, actor string) (*returnModel.Return, error)
+
+	// returns a return by id
+	GetById(id returnModel.ReturnId) (*returnModel.Return, error)
+
+	// returns all returns
+	GetAll() []*returnModel.Return
+
+	// returns all returns of an order
+	GetAllForOrder(orderId orderModel.OrderId) []*returnModel.Return
+}
+
+type service struct {
+	returns			returnModel.Repository
+	orders			order.Service
+	stock			stock.Service
+	payments		payment.Service
+	window			time.Duration
+}
+
+func (s *service) Request(orderId orderModel.OrderId, userId userModel.UserId, items []*returnModel.Item) (*returnModel.Return, error) {
+	if orderId == "" || userId == "" || len(items) == 0 {
+		return nil, ErrInvalidArgument
+	}
+
+	for _, item := range items {
+		if item.ProductId == "" || item.Quantity < 1 || item.Reason == "" {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	o, err := s.orders.GetById(orderId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// other users' orders are treated as if they did not exist
+	if o.UserId != userId {
+		return nil, orderModel.ErrUnknown
+	}
+
+	if o.Status != orderModel.Shipped {
+		return nil, ErrNotReturnable
+	}
+
+	shipped, ok := shippedAt(o)
+
+	if !ok || time.Now().After(shipped.Add(s.window)) {
+		return nil, ErrWindowExpired
+	}
+
+	refund, err := s.refundFor(o, items)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p := paymentModel.Active(s.payments.GetPaymentsForOrder(o.Id)); p != nil {
+		if refund, err = refundable(p, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	// marking the order first makes sure there is only one open return per order
+	if _, err := s.orders.UpdateReturnStatus(orderId, orderModel.ReturnRequested, userId.String()); err != nil {
+		if err == orderModel.ErrInvalidOperation {
+			return nil, ErrNotReturnable
+		}
+		return nil, err
+	}
+
+	created, err := s.returns.Create(&returnModel.Return{
+		Id:			returnModel.NextReturnId(),
+		OrderId:	orderId,
+		UserId:		userId,
+		Items:		items,
+		Status:		returnModel.Requested,
+		Refund:		refund,
+		History:	[]*returnModel.StatusChange{
+			{Status: returnModel.Requested, ChangedAt: time.Now(), Actor: userId.String()},
+		},
+	})
+
+	if err != nil {
+		s.orders.UpdateReturnStatus(orderId, orderModel.Shipped, userId.String())
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *service) Approve(id returnModel.ReturnId, actor string) (*returnModel.Return, error) {
+	if id == "" || actor == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	return s.returns.UpdateStatus(id, &returnModel.StatusChange{Status: returnModel.Approved, ChangedAt: time.Now(), Actor: actor})
+}
+
+func (s *service) Reject(id returnModel.ReturnId, reason string, actor string) (*returnModel.Return, error) {
+	if id == "" || actor == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	rejected, err := s.returns.UpdateStatus(id, &returnModel.StatusChange{Status: returnModel.Rejected, ChangedAt: time.Now(), Actor: actor, Reason: reason})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.orders.UpdateReturnStatus(rejected.OrderId, orderModel.Shipped, actor); err != nil {
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+func (s *service) Receive(id returnModel.ReturnId, actor string) (*returnModel.Return, error) {
+	if id == "" || actor == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	// the return is marked before the refund is issued, so parallel or repeated requests can't refund it twice
+	r, err := s.returns.UpdateStatus(id, &returnModel.StatusChange{Status: returnModel.Refunding, ChangedAt: time.Now(), Actor: actor})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refund(r); err != nil {
+		// receiving the return can be retried once it has been marked as failed
+		s.returns.UpdateStatus(id, &returnModel.StatusChange{Status: returnModel.RefundFailed, ChangedAt: time.Now(), Actor: actor, Reason: err.Error()})
+		return nil, err
+	}
+
+	completed, err := s.returns.UpdateStatus(id, &returnModel.StatusChange{Status: returnModel.Completed, ChangedAt: time.Now(), Actor: actor})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// products that have been removed from the catalog in the meantime can't be restocked
+	for _, item := range completed.Items {
+		s.stock.Add(productModel.NewSimpleProduct(item.ProductId, item.Quantity))
+	}
+
+	o, err := s.orders.GetById(completed.OrderId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	next := orderModel.Shipped
+	if len(remainingQuantities(o, s.returns.FindAllForOrder(o.Id))) == 0 {
+		next = orderModel.Returned
+	}
+
+	if _, err := s.orders.UpdateReturnStatus(o.Id, next, actor); err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+func (s *service) GetById(id returnModel.ReturnId) (*returnModel.Return, error) {
+	if id == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	return s.returns.Find(id)
+}
+
+func (s *service) GetAll() []*returnModel.Return {
+	return s.returns.FindAll()
+}
+
+func (s *service) GetAllForOrder(orderId orderModel.OrderId) []*returnModel.Return {
+	if orderId == "" {
+		return nil
+	}
+
+	return s.returns.FindAllForOrder(orderId)
+}
+
+// checks the items against the quantities that can still be returned and calculates the refund for them
+// the refund contains the share of the tax that has been paid for the items
+func (s *service) refundFor(o *orderModel.Order, items []*returnModel.Item) (money.Money, error) {
+	remaining := remainingQuantities(o, s.returns.FindAllForOrder(o.Id))
+
+	prices := make(map[productModel.ProductId]money.Money)
+	for _, line := range o.Items {
+		prices[line.ProductId] = line.UnitPrice
+	}
+
+	lineTotals := make([]money.Money, 0, len(items))
+	for _, item := range items {
+		// a product that is listed twice has already used up its quantity the first time
+		if item.Quantity > remaining[item.ProductId] {
+			return money.Money{}, ErrInvalidItem
+		}
+		remaining[item.ProductId] -= item.Quantity
+
+		lineTotals = append(lineTotals, prices[item.ProductId].Mul(int64(item.Quantity)))
+	}
+
+	subtotal, err := money.Sum(o.Subtotal.Currency, lineTotals...)
+
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if !o.Subtotal.IsPositive() {
+		return subtotal, nil
+	}
+
+	return subtotal.Add(subtotal.MulRatio(o.Tax.Amount, o.Subtotal.Amount))
+}
+
+// pays back the refund of the return
+// the payment has been captured when the order was shipped - orders without a payment, like the sample orders, have nothing to refund
+func (s *service) refund(r *returnModel.Return) error {
+	p := paymentModel.Active(s.payments.GetPaymentsForOrder(r.OrderId))
+
+	if p == nil {
+		return nil
+	}
+
+	amount, err := refundable(p, r.Refund)
+
+	if err != nil {
+		return err
+	}
+
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	if _, err := s.payments.Refund(p.Id, amount); err != nil {
+		return ErrRefundFailed
+	}
+
+	return nil
+}
+
+// caps the refund at what is left of the captured amount - the tax share is rounded for every return,
+// so the refund of the last return may be a cent more than what is left
+func refundable(p *paymentModel.Payment, refund money.Money) (money.Money, error) {
+	left, err := p.Captured.Sub(p.Refunded)
+
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if c, err := refund.Cmp(left); err != nil {
+		return money.Money{}, err
+	} else if c > 0 {
+		return left, nil
+	}
+
+	return refund, nil
+}
+
+// returns the quantity per product that has not been sent back yet, products without remaining quantity are left out
+// all returns that have not been rejected count, as their items are on their way back
+func remainingQuantities(o *orderModel.Order, returns []*returnModel.Return) map[productModel.ProductId]int {
+	remaining := make(map[productModel.ProductId]int)
+	for _, line := range o.Items {
+		remaining[line.ProductId] += line.Quantity
+	}
+
+	for _, r := range returns {
+		if r.Status == returnModel.Rejected {
+			continue
+		}
+		for _, item := range r.Items {
+			remaining[item.ProductId] -= item.Quantity
+		}
+	}
+
+	for id, quantity := range remaining {
+		if quantity <= 0 {
+			delete(remaining, id)
+		}
+	}
+
+	return remaining
+}
+
+// the return window starts when the order has been shipped for the first time
+func shippedAt(o *orderModel.Order) (time.Time, bool) {
+	for _, change := range o.History {
+		if change.Status == orderModel.Shipped {
+			return change.ChangedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NewService returns a returns service with the necessary dependencies
+// the window is the time after shipping in which a return can be requested
+func NewService(returns returnModel.Repository, orders order.Service, stock stock.Service, payments payment.Service, window time.Duration) Service {
+	return &service{
+		returns:		returns,
+		orders:			orders,
+		stock:			stock,
+		payments:		payments,
+		window:			window,
+	}
+}
\ No newline at end of file
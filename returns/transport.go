@@ -0,0 +1,254 @@
+package returns
+
+import (
+	kitlog "github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"encoding/json"
+	"net/http"
+	"io"
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	userModel "github.com/MICSTI/imsazon/models/user"
+	productModel "github.com/MICSTI/imsazon/models/product"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	returnModel "github.com/MICSTI/imsazon/models/returns"
+	"errors"
+)
+
+var ErrBadRoute = errors.New("Bad route")
+
+// MakeHandler returns a handler for the returns service.
+func MakeHandler(rs Service, as auth.Service, logger kitlog.Logger) http.Handler {
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
+	}
+
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	// only admins decide about returns
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin)
+
+	requestHandler := kithttp.NewServer(
+		authenticate(makeRequestEndpoint(rs)),
+		decodeRequestRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	approveHandler := kithttp.NewServer(
+		authenticate(authorize(makeApproveEndpoint(rs))),
+		decodeApproveRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	rejectHandler := kithttp.NewServer(
+		authenticate(authorize(makeRejectEndpoint(rs))),
+		decodeRejectRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	receiveHandler := kithttp.NewServer(
+		authenticate(authorize(makeReceiveEndpoint(rs))),
+		decodeReceiveRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getByIdHandler := kithttp.NewServer(
+		authenticate(makeGetByIdEndpoint(rs)),
+		decodeGetByIdRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getAllHandler := kithttp.NewServer(
+		authenticate(authorize(makeGetAllEndpoint(rs))),
+		decodeGetAllRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	getAllForOrderHandler := kithttp.NewServer(
+		authenticate(makeGetAllForOrderEndpoint(rs)),
+		decodeGetAllForOrderRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/returns/request", requestHandler).Methods("POST")
+	r.Handle("/returns/approve/{returnId}", approveHandler).Methods("POST")
+	r.Handle("/returns/reject/{returnId}", rejectHandler).Methods("POST")
+	r.Handle("/returns/receive/{returnId}", receiveHandler).Methods("POST")
+	r.Handle("/returns/single/{returnId}", getByIdHandler).Methods("GET")
+	r.Handle("/returns/all", getAllHandler).Methods("GET")
+	r.Handle("/returns/order/{orderId}", getAllForOrderHandler).Methods("GET")
+
+	return r
+}
+
+func decodeRequestRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		OrderId			orderModel.OrderId			`json:"orderId"`
+		Items			[]*returnModel.Item			`json:"items"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return requestRequest{
+		OrderId:	body.OrderId,
+		Items:		body.Items,
+	}, nil
+}
+
+// reads the return id from the route
+func returnIdFromRoute(r *http.Request) (returnModel.ReturnId, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["returnId"]
+
+	if !ok {
+		return "", ErrBadRoute
+	}
+
+	return returnModel.ReturnId(id), nil
+}
+
+func decodeApproveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := returnIdFromRoute(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return approveRequest{
+		Id:		id,
+	}, nil
+}
+
+func decodeRejectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := returnIdFromRoute(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the reason is optional, so an empty body is fine as well
+	var body struct {
+		Reason			string						`json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return rejectRequest{
+		Id:			id,
+		Reason:		body.Reason,
+	}, nil
+}
+
+func decodeReceiveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := returnIdFromRoute(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveRequest{
+		Id:		id,
+	}, nil
+}
+
+func decodeGetByIdRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := returnIdFromRoute(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getByIdRequest{
+		Id:		id,
+	}, nil
+}
+
+func decodeGetAllRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return getAllRequest{}, nil
+}
+
+func decodeGetAllForOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	orderId, ok := vars["orderId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return getAllForOrderRequest{
+		OrderId:	orderModel.OrderId(orderId),
+	}, nil
+}
+
+// encode the JSON response
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(erroer); ok && e.error() != nil {
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+type erroer interface {
+	error() error
+}
+
+// encode errors from business logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	case ErrInvalidArgument:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidItem:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrNotReturnable:
+		w.WriteHeader(http.StatusConflict)
+	case ErrWindowExpired:
+		w.WriteHeader(http.StatusConflict)
+	case ErrRefundFailed:
+		w.WriteHeader(http.StatusBadGateway)
+	case ErrBadRoute:
+		w.WriteHeader(http.StatusBadRequest)
+	case returnModel.ErrUnknown:
+		w.WriteHeader(http.StatusNotFound)
+	case returnModel.ErrInvalidOperation:
+		w.WriteHeader(http.StatusConflict)
+	case orderModel.ErrUnknown:
+		w.WriteHeader(http.StatusBadRequest)
+	case orderModel.ErrInvalidOperation:
+		w.WriteHeader(http.StatusConflict)
+	case productModel.ErrProductUnknown:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
\ No newline at end of file
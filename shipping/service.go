@@ -3,17 +3,24 @@ package shipping
 import (
 	"errors"
 	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
 	"time"
 	"net/http"
 	"encoding/json"
 	"io/ioutil"
 	"bytes"
 	"github.com/MICSTI/imsazon/mail"
+	"github.com/MICSTI/imsazon/auth"
 )
 
 const getSingleOrderApiUrl = "http://localhost:8605/order/single/"
 const updateOrderStatusApiUrl = "http://localhost:8605/order/update/"
 const sendMailApiUrl = "http://localhost:8605/mail/send"
+const getOrderPaymentsApiUrl = "http://localhost:8605/payment/order/"
+const capturePaymentApiUrl = "http://localhost:8605/payment/capture/"
+
+// name the shipping service uses to identify itself when calling other services
+const serviceName = "shipping"
 
 // ErrInvalidArgument is returned when one or more arguments are invalid.
 var ErrInvalidArgument = errors.New("Invalid argument")
@@ -21,6 +28,9 @@ var ErrShippingNotPossible = errors.New("Shipping is currently not possible for
 var ErrInvalidOperation = errors.New("Invalid operation")
 var ErrApi = errors.New("Error response from API")
 
+// ErrCaptureFailed is returned when the authorized payment of an order could not be captured, the order is not shipped then
+var ErrCaptureFailed = errors.New("The payment of the order could not be captured")
+
 // Service is the interface that provides the shipping methods
 type Service interface {
 	// Ships the order from the physical store
@@ -29,6 +39,7 @@ type Service interface {
 
 type service struct {
 	testMailRecipient		string
+	auth					auth.Service
 }
 
 type OrderStatusApiResponse struct {
@@ -41,7 +52,7 @@ func (s *service) Ship(orderId orderModel.OrderId) (error) {
 	}
 
 	// check the order service if the current order status is "Payment Successful"
-	currentOrderStatus, err := getOrderStatus(orderId)
+	currentOrderStatus, err := s.getOrderStatus(orderId)
 
 	if err != nil {
 		return err
@@ -55,8 +66,15 @@ func (s *service) Ship(orderId orderModel.OrderId) (error) {
 	duration := time.Millisecond * 750
 	time.Sleep(duration)
 
+	// the money is only taken when the order actually ships
+	err = s.capturePayment(orderId)
+
+	if err != nil {
+		return err
+	}
+
 	// call order service to mark order as "shipped"
-	err = setOrderStatus(orderId, orderModel.Shipped)
+	err = s.setOrderStatus(orderId, orderModel.Shipped)
 
 	if err != nil {
 		return ErrShippingNotPossible
@@ -65,7 +83,7 @@ func (s *service) Ship(orderId orderModel.OrderId) (error) {
 	// call the mail service to send out an email that the order was shipped successfully
 	mailToSend := mail.New(s.testMailRecipient, "Your order has been shipped", successfulShippingMailBody, "text/html")
 
-	err = sendMail(mailToSend)
+	err = s.sendMail(mailToSend)
 
 	return nil
 }
@@ -79,8 +97,31 @@ func parseOrderStatusResponse(body []byte) (*OrderStatusApiResponse, error) {
 	return s, err
 }
 
-func getOrderStatus(id orderModel.OrderId) (orderModel.OrderStatus, error) {
-	resp, err := http.Get(getSingleOrderApiUrl + id.String())
+// sends an API request to another service, authenticated with a service token
+func (s *service) doApiRequest(method string, url string, body []byte) (*http.Response, error) {
+	token, err := s.auth.ServiceToken(serviceName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer " + token)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+func (s *service) getOrderStatus(id orderModel.OrderId) (orderModel.OrderStatus, error) {
+	resp, err := s.doApiRequest("GET", getSingleOrderApiUrl + id.String(), nil)
 
 	if err != nil {
 		return orderModel.Created, ErrApi
@@ -103,7 +144,7 @@ func getOrderStatus(id orderModel.OrderId) (orderModel.OrderStatus, error) {
 	return parsed.Order.Status, nil
 }
 
-func setOrderStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) error {
+func (s *service) setOrderStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) error {
 	message := map[string]interface{}{
 		"status": newStatus,
 	}
@@ -113,7 +154,7 @@ func setOrderStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) err
 		return err
 	}
 
-	resp, err := http.Post(updateOrderStatusApiUrl + id.String(), "application/json", bytes.NewBuffer(bytesRepresentation))
+	resp, err := s.doApiRequest("POST", updateOrderStatusApiUrl + id.String(), bytesRepresentation)
 
 	if err != nil {
 		return err
@@ -128,7 +169,82 @@ func setOrderStatus(id orderModel.OrderId, newStatus orderModel.OrderStatus) err
 	return nil
 }
 
-func sendMail(mail *mail.Email) error {
+type OrderPaymentsApiResponse struct {
+	Payments		[]*paymentModel.Payment		`json:"payments"`
+}
+
+// captures the authorized payment of the order
+// a payment that has already been captured counts as success, so shipping can be retried if marking the order as shipped failed
+func (s *service) capturePayment(id orderModel.OrderId) error {
+	p, err := s.getActivePayment(id)
+
+	if err != nil {
+		return err
+	}
+
+	switch p.Status {
+	case paymentModel.Captured:
+		return nil
+	case paymentModel.Authorized:
+	default:
+		// a refunded or disputed payment does not pay for the shipment
+		return ErrCaptureFailed
+	}
+
+	// without an amount the whole authorized amount is captured
+	resp, err := s.doApiRequest("POST", capturePaymentApiUrl + p.Id.String(), nil)
+
+	if err != nil {
+		return ErrApi
+	}
+
+	defer resp.Body.Close()
+
+	// a parallel attempt may have captured the payment in the meantime
+	if resp.StatusCode == http.StatusConflict {
+		if p, err := s.getActivePayment(id); err == nil && p.Status == paymentModel.Captured {
+			return nil
+		}
+		return ErrCaptureFailed
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return ErrCaptureFailed
+	}
+
+	return nil
+}
+
+// returns the latest payment of the order that has not failed or been voided, ErrCaptureFailed if there is none
+func (s *service) getActivePayment(id orderModel.OrderId) (*paymentModel.Payment, error) {
+	resp, err := s.doApiRequest("GET", getOrderPaymentsApiUrl + id.String(), nil)
+
+	if err != nil {
+		return nil, ErrApi
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrApi
+	}
+
+	var parsed OrderPaymentsApiResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, ErrApi
+	}
+
+	p := paymentModel.Active(parsed.Payments)
+
+	if p == nil {
+		return nil, ErrCaptureFailed
+	}
+
+	return p, nil
+}
+
+func (s *service) sendMail(mail *mail.Email) error {
 	message := map[string]interface{}{
 		"to": mail.To,
 		"subject": mail.Subject,
@@ -141,7 +257,7 @@ func sendMail(mail *mail.Email) error {
 		return err
 	}
 
-	resp, err := http.Post(sendMailApiUrl, "application/json", bytes.NewBuffer(bytesRepresentation))
+	resp, err := s.doApiRequest("POST", sendMailApiUrl, bytesRepresentation)
 
 	if err != nil {
 		return err
@@ -157,9 +273,10 @@ func sendMail(mail *mail.Email) error {
 }
 
 // NewService creates a shipping service
-func NewService(testMailRecipient string) Service {
+func NewService(testMailRecipient string, as auth.Service) Service {
 	return &service{
 		testMailRecipient: testMailRecipient,
+		auth: as,
 	}
 }
 
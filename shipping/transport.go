@@ -8,18 +8,24 @@ import (
 	"net/http"
 	"errors"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	userModel "github.com/MICSTI/imsazon/models/user"
 	orderModel "github.com/MICSTI/imsazon/models/order"
 )
 
 // MakeHandler returns a handler for the shipping service.
-func MakeHandler(shs Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(shs Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin, userModel.Service)
+
 	shipHandler := kithttp.NewServer(
-		makeShipEndpoint(shs),
+		authenticate(authorize(makeShipEndpoint(shs))),
 		decodeShipRequest,
 		encodeResponse,
 		opts...,
@@ -64,6 +70,16 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		w.WriteHeader(http.StatusBadRequest)
 	case ErrBadRoute:
 		w.WriteHeader(http.StatusBadRequest)
+	case ErrCaptureFailed:
+		w.WriteHeader(http.StatusBadGateway)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -4,14 +4,18 @@ import (
 	productModel "github.com/MICSTI/imsazon/models/product"
 	"github.com/go-kit/kit/endpoint"
 	"context"
+	"time"
 )
 
 type getItemsRequest struct {
-
+	Query		productModel.Query
 }
 
 type getItemsResponse struct {
 	Products	[]*productModel.Product	`json:"products,omitempty"`
+	Total		int					`json:"total"`
+	NextCursor	string				`json:"nextCursor,omitempty"`
+	Facets		*productModel.Facets	`json:"facets,omitempty"`
 	Err			error				`json:"error,omitempty"`
 }
 
@@ -19,13 +23,14 @@ func (r getItemsResponse) error() error { return r.Err }
 
 func makeGetItemsEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		products := s.GetItems()
-		return getItemsResponse{Products: products, Err: nil}, nil
+		req := request.(getItemsRequest)
+		page, err := s.GetItems(req.Query)
+		return getItemsResponse{Products: page.Products, Total: page.Total, NextCursor: page.NextCursor, Facets: page.Facets, Err: err}, nil
 	}
 }
 
 type addRequest struct {
-	Product		productModel.Product
+	Item		productModel.SimpleProduct
 }
 
 type addResponse struct {
@@ -38,14 +43,14 @@ func (r addResponse) error() error { return r.Err }
 func makeAddEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(addRequest)
-		updatedProduct, err := s.Add(&req.Product)
+		updatedProduct, err := s.Add(&req.Item)
 
 		return addResponse{UpdatedProduct: updatedProduct, Err: err}, nil
 	}
 }
 
 type withdrawRequest struct {
-	Product		productModel.Product
+	Item		productModel.SimpleProduct
 }
 
 type withdrawResponse struct {
@@ -58,7 +63,84 @@ func (r withdrawResponse) error() error { return r.Err }
 func makeWithdrawEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(withdrawRequest)
-		updatedProduct, err := s.Withdraw(&req.Product)
+		updatedProduct, err := s.Withdraw(&req.Item)
 		return withdrawResponse{UpdatedProduct: updatedProduct, Err: err}, nil
 	}
+}
+
+type withdrawBatchRequest struct {
+	Items		[]*productModel.SimpleProduct
+}
+
+type withdrawBatchResponse struct {
+	UpdatedProducts		[]*productModel.Product	`json:"products,omitempty"`
+	Err					error				`json:"error,omitempty"`
+}
+
+func (r withdrawBatchResponse) error() error { return r.Err }
+
+func makeWithdrawBatchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(withdrawBatchRequest)
+		updatedProducts, err := s.WithdrawBatch(req.Items)
+		return withdrawBatchResponse{UpdatedProducts: updatedProducts, Err: err}, nil
+	}
+}
+
+type reserveRequest struct {
+	ProductId		productModel.ProductId
+	Quantity		int
+	TTL				time.Duration
+}
+
+type reserveResponse struct {
+	Reservation		*productModel.Reservation	`json:"reservation,omitempty"`
+	Err				error						`json:"error,omitempty"`
+}
+
+func (r reserveResponse) error() error { return r.Err }
+
+func makeReserveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(reserveRequest)
+		reservation, err := s.Reserve(req.ProductId, req.Quantity, req.TTL)
+		return reserveResponse{Reservation: reservation, Err: err}, nil
+	}
+}
+
+type commitRequest struct {
+	Id			productModel.ReservationId
+}
+
+type commitResponse struct {
+	UpdatedProduct		*productModel.Product	`json:"product,omitempty"`
+	Err					error				`json:"error,omitempty"`
+}
+
+func (r commitResponse) error() error { return r.Err }
+
+func makeCommitEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(commitRequest)
+		updatedProduct, err := s.Commit(req.Id)
+		return commitResponse{UpdatedProduct: updatedProduct, Err: err}, nil
+	}
+}
+
+type releaseRequest struct {
+	Id			productModel.ReservationId
+}
+
+type releaseResponse struct {
+	Err			error		`json:"error,omitempty"`
+}
+
+func (r releaseResponse) error() error { return r.Err }
+
+func makeReleaseEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(releaseRequest)
+		err := s.Release(req.Id)
+		return releaseResponse{Err: err}, nil
+	}
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package stock
 import (
 	"github.com/go-kit/kit/log"
 	productModel "github.com/MICSTI/imsazon/models/product"
+	"strings"
 	"time"
 )
 
@@ -16,24 +17,58 @@ func NewLoggingService(logger log.Logger, s Service) Service {
 	return &loggingService{logger, s}
 }
 
-func (s *loggingService) GetItems() (products []*productModel.Product) {
+func (s *loggingService) GetItems(query productModel.Query) (page *productModel.Page, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log("method", "GetItems", "took", time.Since(begin), "err", nil)
+		s.logger.Log("method", "GetItems", "text", query.Text, "categories", strings.Join(query.Categories, ","), "sort", query.SortBy, "results", len(page.Products), "took", time.Since(begin), "err", err)
 	}(time.Now())
-	return s.Service.GetItems()
+	return s.Service.GetItems(query)
 }
 
-func (s *loggingService) Add(productToAdd *productModel.Product) (updatedProduct *productModel.Product, err error) {
+func (s *loggingService) Add(item *productModel.SimpleProduct) (updatedProduct *productModel.Product, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log("method", "Add", "product_id", updatedProduct.Id, "took", time.Since(begin), "err", err)
+		s.logger.Log("method", "Add", "product_id", item.Id, "quantity", item.Quantity, "took", time.Since(begin), "err", err)
 	}(time.Now())
-	return s.Service.Add(productToAdd)
+	return s.Service.Add(item)
 }
 
-func (s *loggingService) Withdraw(productToWithdraw *productModel.Product) (updatedProduct *productModel.Product, err error) {
+func (s *loggingService) Withdraw(item *productModel.SimpleProduct) (updatedProduct *productModel.Product, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log("method", "Withdraw", "product_id", updatedProduct.Id, "took", time.Since(begin), "err", err)
+		s.logger.Log("method", "Withdraw", "product_id", item.Id, "quantity", item.Quantity, "took", time.Since(begin), "err", err)
 	}(time.Now())
-	return s.Service.Withdraw(productToWithdraw)
+	return s.Service.Withdraw(item)
 }
 
+func (s *loggingService) WithdrawBatch(items []*productModel.SimpleProduct) (updatedProducts []*productModel.Product, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "WithdrawBatch", "items", len(items), "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.WithdrawBatch(items)
+}
+
+func (s *loggingService) Reserve(productId productModel.ProductId, quantity int, ttl time.Duration) (reservation *productModel.Reservation, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Reserve", "product_id", productId, "quantity", quantity, "reservation_id", reservation.Id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Reserve(productId, quantity, ttl)
+}
+
+func (s *loggingService) Commit(id productModel.ReservationId) (updatedProduct *productModel.Product, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Commit", "reservation_id", id, "product_id", updatedProduct.Id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Commit(id)
+}
+
+func (s *loggingService) Release(id productModel.ReservationId) (err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "Release", "reservation_id", id, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.Release(id)
+}
+
+func (s *loggingService) ExpireReservations() (expired int, err error) {
+	defer func(begin time.Time) {
+		s.logger.Log("method", "ExpireReservations", "expired", expired, "took", time.Since(begin), "err", err)
+	}(time.Now())
+	return s.Service.ExpireReservations()
+}
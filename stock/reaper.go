@@ -0,0 +1,29 @@
+package stock
+
+import (
+	"time"
+)
+
+// StartReaper periodically removes expired reservations in the background.
+// Calling the returned function stops the reaper.
+func StartReaper(s Service, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				// errors are logged by the logging service, the next run simply tries again
+				s.ExpireReservations()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
\ No newline at end of file
@@ -3,64 +3,182 @@
 	The stock service is responsible for keeping track of the inventory of IMSazon.
 	It provides information about all stock items and their quantity in the store.
 	It also provides methods to add and withdraw items from the store.
+	The stock service only changes quantities - products are created and described by the catalog service.
+	Items can be reserved for a limited time, so they can't be sold to anyone else until the reservation is
+	committed (the items are withdrawn), released or has expired. Expired reservations are removed by the reaper.
  */
 package stock
 
 import (
 	"errors"
 	productModel "github.com/MICSTI/imsazon/models/product"
+	categoryModel "github.com/MICSTI/imsazon/models/category"
 	"sort"
+	"time"
 )
 
 // ErrInvalidArgument is returned when on or more arguments are invalid
 var ErrInvalidArgument = errors.New("Invalid argument")
 
+// reservations can't be held longer than this
+const maxReservationTTL = time.Hour
+
 type Service interface {
-	// GetItems returns an array of all stock products including their quantity
-	GetItems() []*productModel.Product
+	// GetItems returns the page of stock products matching the query including their quantity.
+	// Filtering by a category includes all its sub categories, the category facet counts include the products of the sub categories as well.
+	GetItems(query productModel.Query) (*productModel.Page, error)
 
-	// Add adds an item with the specified quantity to the stock. Returns a new product object with the updated stock information.
-	Add(productToAdd *productModel.Product) (*productModel.Product, error)
+	// Add adds the specified quantity of an existing product to the stock. Returns a new product object with the updated stock information.
+	Add(item *productModel.SimpleProduct) (*productModel.Product, error)
 
 	// Withdraw removes the specified quantity from the stock. Returns a new product object with the updated stock information.
-	Withdraw(productToWithdraw *productModel.Product) (*productModel.Product, error)
+	Withdraw(item *productModel.SimpleProduct) (*productModel.Product, error)
+
+	// WithdrawBatch removes the specified quantities of several items from the stock at once.
+	// If a single item is not available in the requested quantity, nothing is withdrawn.
+	// Returns new product objects with the updated stock information.
+	WithdrawBatch(items []*productModel.SimpleProduct) ([]*productModel.Product, error)
+
+	// Reserve holds the specified quantity of a product for the duration of the ttl.
+	// Reserved items are not available for withdrawals or other reservations.
+	Reserve(productId productModel.ProductId, quantity int, ttl time.Duration) (*productModel.Reservation, error)
+
+	// Commit withdraws the reserved items from the stock. Returns a new product object with the updated stock information.
+	Commit(id productModel.ReservationId) (*productModel.Product, error)
+
+	// Release gives the reserved items back without withdrawing them
+	Release(id productModel.ReservationId) error
+
+	// ExpireReservations removes all reservations that have expired. Returns the number of removed reservations.
+	ExpireReservations() (int, error)
 }
 
 type service struct {
 	products		productModel.Repository
+	categories		categoryModel.Repository
+}
+
+func(s *service) GetItems(query productModel.Query) (*productModel.Page, error) {
+	if query.Limit < 0 || query.Limit > productModel.MaxPageSize || query.MinPrice.IsNegative() || query.MaxPrice.IsNegative() {
+		return &productModel.Page{}, ErrInvalidArgument
+	}
+
+	if !query.MinPrice.IsZero() && !query.MaxPrice.IsZero() {
+		if c, err := query.MinPrice.Cmp(query.MaxPrice); err != nil || c > 0 {
+			return &productModel.Page{}, ErrInvalidArgument
+		}
+	}
+
+	if _, err := productModel.ParseSortField(string(query.SortBy)); err != nil {
+		return &productModel.Page{}, ErrInvalidArgument
+	}
+
+	categories := s.categories.FindAll()
+
+	if len(query.Categories) > 0 {
+		expanded := []string{}
+		for _, id := range query.Categories {
+			for _, descendant := range categoryModel.Descendants(categories, categoryModel.CategoryId(id)) {
+				expanded = append(expanded, descendant.String())
+			}
+		}
+		query.Categories = expanded
+	}
+
+	page, err := s.products.Search(query)
+
+	if err != nil {
+		return &productModel.Page{}, err
+	}
+
+	// the repository only counts the category a product is directly assigned to
+	if page.Facets != nil {
+		counts := make(map[string]int)
+		for id, count := range page.Facets.Categories {
+			counts[id] += count
+			for _, ancestor := range categoryModel.Ancestors(categories, categoryModel.CategoryId(id)) {
+				counts[ancestor.String()] += count
+			}
+		}
+		page.Facets.Categories = counts
+	}
+
+	return page, nil
+}
+
+func(s *service) Add(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	if item.Id == "" || item.Quantity < 1 {
+		return &productModel.Product{}, ErrInvalidArgument
+	}
+
+	p, err := s.products.Add(item)
+
+	if err != nil {
+		return &productModel.Product{}, err
+	}
+
+	return p, nil
+}
+
+func(s *service) Withdraw(item *productModel.SimpleProduct) (*productModel.Product, error) {
+	if item.Id == "" || item.Quantity < 1 {
+		return &productModel.Product{}, ErrInvalidArgument
+	}
+
+	p, err := s.products.Withdraw(item)
+
+	if err != nil {
+		return &productModel.Product{}, err
+	}
+
+	return p, nil
 }
 
-func(s *service) GetItems() []*productModel.Product {
-	p := s.products.FindAll()
+func(s *service) WithdrawBatch(items []*productModel.SimpleProduct) ([]*productModel.Product, error) {
+	if len(items) == 0 {
+		return []*productModel.Product{}, ErrInvalidArgument
+	}
+
+	for _, item := range items {
+		if item == nil || item.Id == "" || item.Quantity < 1 {
+			return []*productModel.Product{}, ErrInvalidArgument
+		}
+	}
+
+	p, err := s.products.WithdrawBatch(items)
+
+	if err != nil {
+		return []*productModel.Product{}, err
+	}
 
 	// sort products by ID so always the same order will be returned
 	sort.Slice(p, func(i, j int) bool {
 		return p[i].Id < p[j].Id
 	})
 
-	return p
+	return p, nil
 }
 
-func(s *service) Add(productToAdd *productModel.Product) (*productModel.Product, error) {
-	if productToAdd.Id == "" {
-		return &productModel.Product{}, ErrInvalidArgument
+func(s *service) Reserve(productId productModel.ProductId, quantity int, ttl time.Duration) (*productModel.Reservation, error) {
+	if productId == "" || quantity < 1 || ttl <= 0 || ttl > maxReservationTTL {
+		return &productModel.Reservation{}, ErrInvalidArgument
 	}
 
-	p, err := s.products.Add(productToAdd)
+	r, err := s.products.Reserve(productModel.NewReservation(productModel.NextReservationId(), productId, quantity, time.Now().Add(ttl)))
 
 	if err != nil {
-		return &productModel.Product{}, err
+		return &productModel.Reservation{}, err
 	}
 
-	return p, nil
+	return r, nil
 }
 
-func(s *service) Withdraw(productToWithdraw *productModel.Product) (*productModel.Product, error) {
-	if productToWithdraw.Id == "" {
+func(s *service) Commit(id productModel.ReservationId) (*productModel.Product, error) {
+	if id == "" {
 		return &productModel.Product{}, ErrInvalidArgument
 	}
 
-	p, err := s.products.Withdraw(productToWithdraw)
+	p, err := s.products.CommitReservation(id)
 
 	if err != nil {
 		return &productModel.Product{}, err
@@ -69,8 +187,27 @@ func(s *service) Withdraw(productToWithdraw *productModel.Product) (*productMode
 	return p, nil
 }
 
-func NewService(products productModel.Repository) Service {
+func(s *service) Release(id productModel.ReservationId) error {
+	if id == "" {
+		return ErrInvalidArgument
+	}
+
+	return s.products.ReleaseReservation(id)
+}
+
+func(s *service) ExpireReservations() (int, error) {
+	expired, err := s.products.ExpireReservations(time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(expired), nil
+}
+
+func NewService(products productModel.Repository, categories categoryModel.Repository) Service {
 	return &service{
 		products: products,
+		categories: categories,
 		}
 }
\ No newline at end of file
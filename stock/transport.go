@@ -1,60 +1,167 @@
 package stock
 
 import (
+	"errors"
+	"strconv"
+	"time"
 	kitlog "github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"encoding/json"
 	"context"
 	"net/http"
 	productModel "github.com/MICSTI/imsazon/models/product"
+	"github.com/MICSTI/imsazon/models/money"
 	"github.com/gorilla/mux"
+	"github.com/MICSTI/imsazon/auth"
+	userModel "github.com/MICSTI/imsazon/models/user"
 )
 
+// ErrBadRoute is returned when a route parameter is missing
+var ErrBadRoute = errors.New("Bad route")
+
 // MakeHandler returns a handler for the stock service
-func MakeHandler(sts Service, logger kitlog.Logger) http.Handler {
+func MakeHandler(sts Service, as auth.Service, logger kitlog.Logger) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(auth.HTTPToContext()),
 	}
 
+	authenticate := auth.NewAuthenticationMiddleware(as)
+	authorize := auth.NewAuthorizationMiddleware(userModel.Admin, userModel.Service)
+
 	getItemsHandler := kithttp.NewServer(
-		makeGetItemsEndpoint(sts),
+		authenticate(makeGetItemsEndpoint(sts)),
 		decodeGetItemRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	addHandler := kithttp.NewServer(
-		makeAddEndpoint(sts),
+		authenticate(authorize(makeAddEndpoint(sts))),
 		decodeAddRequest,
 		encodeResponse,
 		opts...,
 	)
 
 	withdrawHandler := kithttp.NewServer(
-		makeWithdrawEndpoint(sts),
+		authenticate(authorize(makeWithdrawEndpoint(sts))),
 		decodeWithdrawRequest,
 		encodeResponse,
 		opts...,
 	)
 
+	withdrawBatchHandler := kithttp.NewServer(
+		authenticate(authorize(makeWithdrawBatchEndpoint(sts))),
+		decodeWithdrawBatchRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	reserveHandler := kithttp.NewServer(
+		authenticate(authorize(makeReserveEndpoint(sts))),
+		decodeReserveRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	commitHandler := kithttp.NewServer(
+		authenticate(authorize(makeCommitEndpoint(sts))),
+		decodeCommitRequest,
+		encodeResponse,
+		opts...,
+	)
+
+	releaseHandler := kithttp.NewServer(
+		authenticate(authorize(makeReleaseEndpoint(sts))),
+		decodeReleaseRequest,
+		encodeResponse,
+		opts...,
+	)
+
 	r := mux.NewRouter()
 
 	r.Handle("/stock/items", getItemsHandler).Methods("GET")
 	r.Handle("/stock/add", addHandler).Methods("POST")
 	r.Handle("/stock/withdraw", withdrawHandler).Methods("POST")
+	r.Handle("/stock/withdraw/batch", withdrawBatchHandler).Methods("POST")
+	r.Handle("/stock/reserve", reserveHandler).Methods("POST")
+	r.Handle("/stock/reservations/{reservationId}/commit", commitHandler).Methods("POST")
+	r.Handle("/stock/reservations/{reservationId}/release", releaseHandler).Methods("POST")
 
 	return r
 }
 
+// reads the search, filter, sort and pagination parameters from the query string
+// e.g. /stock/items?q=lightsaber&category=weapons&minPrice=10&maxPrice=1000&inStock=true&sort=price&order=desc&limit=10
 func decodeGetItemRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	// there are no parameters to the request, so we don't need to decode anything
-	return getItemsRequest{}, nil
+	params := r.URL.Query()
+
+	query := productModel.Query{
+		Text:		params.Get("q"),
+		Categories:	params["category"],
+		Cursor:		params.Get("cursor"),
+	}
+
+	var err error
+
+	// prices are read in the passed currency, e.g. &currency=USD
+	currency := money.DefaultCurrency
+	if v := params.Get("currency"); v != "" {
+		if currency, err = money.ParseCurrency(v); err != nil {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	if query.MinPrice, err = parsePriceParam(params.Get("minPrice"), currency); err != nil {
+		return nil, ErrInvalidArgument
+	}
+
+	if query.MaxPrice, err = parsePriceParam(params.Get("maxPrice"), currency); err != nil {
+		return nil, ErrInvalidArgument
+	}
+
+	if v := params.Get("inStock"); v != "" {
+		if query.InStock, err = strconv.ParseBool(v); err != nil {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	if query.SortBy, err = productModel.ParseSortField(params.Get("sort")); err != nil {
+		return nil, ErrInvalidArgument
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return nil, ErrInvalidArgument
+	}
+
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, ErrInvalidArgument
+		}
+	}
+
+	return getItemsRequest{
+		Query:		query,
+	}, nil
+}
+
+// parses an optional price query parameter, an empty value is returned as zero amount
+func parsePriceParam(v string, currency money.Currency) (money.Money, error) {
+	if v == "" {
+		return money.Money{}, nil
+	}
+	return money.Parse(v, currency)
 }
 
 func decodeAddRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		ProductToAdd		productModel.Product		`json:"product"`
+		ProductToAdd		productModel.SimpleProduct		`json:"product"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -62,13 +169,13 @@ func decodeAddRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	}
 
 	return addRequest{
-		Product:		body.ProductToAdd,
+		Item:		body.ProductToAdd,
 	}, nil
 }
 
 func decodeWithdrawRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var body struct {
-		ProductToWithdraw	productModel.Product		`json:"product"`
+		ProductToWithdraw	productModel.SimpleProduct		`json:"product"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -76,7 +183,67 @@ func decodeWithdrawRequest(_ context.Context, r *http.Request) (interface{}, err
 	}
 
 	return withdrawRequest{
-		Product:		body.ProductToWithdraw,
+		Item:		body.ProductToWithdraw,
+	}, nil
+}
+
+func decodeWithdrawBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		Items		[]*productModel.SimpleProduct		`json:"items"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return withdrawBatchRequest{
+		Items:		body.Items,
+	}, nil
+}
+
+func decodeReserveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		ProductId		productModel.ProductId		`json:"productId"`
+		Quantity		int							`json:"quantity"`
+		TTL				int							`json:"ttl"`			// seconds
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	return reserveRequest{
+		ProductId:		body.ProductId,
+		Quantity:		body.Quantity,
+		TTL:			time.Duration(body.TTL) * time.Second,
+	}, nil
+}
+
+func decodeCommitRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["reservationId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return commitRequest{
+		Id:		productModel.ReservationId(id),
+	}, nil
+}
+
+func decodeReleaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	id, ok := vars["reservationId"]
+
+	if !ok {
+		return nil, ErrBadRoute
+	}
+
+	return releaseRequest{
+		Id:		productModel.ReservationId(id),
 	}, nil
 }
 
@@ -104,6 +271,22 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		w.WriteHeader(http.StatusBadRequest)
 	case productModel.ErrProductUnknown:
 		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrReservationUnknown:
+		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrReservationExpired:
+		w.WriteHeader(http.StatusConflict)
+	case ErrBadRoute:
+		w.WriteHeader(http.StatusBadRequest)
+	case productModel.ErrInvalidCursor:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrMissingToken:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrInvalid:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrExpired:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"github.com/go-kit/kit/endpoint"
+	"context"
+)
+
+type handleRequest struct {
+	Event			*Event
+}
+
+type handleResponse struct {
+	Received		bool			`json:"received"`
+	Result			Result			`json:"result,omitempty"`
+	Err				error			`json:"error,omitempty"`
+}
+
+func (r handleResponse) error() error { return r.Err }
+
+func makeHandleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(handleRequest)
+		result, err := s.Handle(req.Event)
+		return handleResponse{Received: err == nil, Result: result, Err: err}, nil
+	}
+}
\ No newline at end of file
@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"github.com/go-kit/kit/log"
+	"time"
+)
+
+type loggingService struct {
+	logger log.Logger
+	Service
+}
+
+// NewLoggingService returns a new instance of a logging service
+func NewLoggingService(logger log.Logger, s Service) Service {
+	return &loggingService{logger, s}
+}
+
+func (s *loggingService) Handle(event *Event) (result Result, err error) {
+	defer func(begin time.Time) {
+		var eventId, eventType string
+		if event != nil {
+			eventId = event.Id
+			eventType = event.Type
+		}
+		s.logger.Log(
+			"method", "Handle",
+			"eventId", eventId,
+			"type", eventType,
+			"result", result,
+			"took", time.Since(begin),
+			"err", err,
+		)
+	}(time.Now())
+	return s.Service.Handle(event)
+}
\ No newline at end of file
@@ -0,0 +1,288 @@
+/*
+	The webhook service receives the events the payment provider sends when the outcome of a payment changes
+	after the request that started it has returned - e.g. a charge that succeeds later on, a refund issued in the
+	dashboard of the provider or a dispute opened by the card holder. The events use the format of Stripe.
+	Every event is checked against its HMAC signature, old events are rejected and events that have already been
+	processed are only acknowledged, as providers deliver events at least once.
+	The payments are updated with the outcome and the orders move on to the matching status.
+	Events can arrive late and in any order, so orders that have already moved on are left alone.
+ */
+package webhook
+
+import (
+	"errors"
+	"time"
+	"github.com/MICSTI/imsazon/models/money"
+	idempotencyModel "github.com/MICSTI/imsazon/models/idempotency"
+	orderModel "github.com/MICSTI/imsazon/models/order"
+	paymentModel "github.com/MICSTI/imsazon/models/payment"
+	"github.com/MICSTI/imsazon/order"
+	"github.com/MICSTI/imsazon/payment"
+)
+
+// name the webhook uses as actor for the status changes of orders
+const serviceName = "payment-webhook"
+
+// processed events are stored with this prefix in the idempotency repository
+const eventKeyPrefix = "webhook:"
+
+// DefaultRetention is used when no retention is configured, providers retry failed deliveries for up to three days
+const DefaultRetention = time.Hour * 72
+
+// ErrInvalidEvent is returned when an event could not be read or misses its id or type
+var ErrInvalidEvent = errors.New("Invalid event")
+
+// ErrInProgress is returned when an event is delivered again while its first delivery is still being processed
+var ErrInProgress = errors.New("The event is still being processed")
+
+// event types of the payment provider that are processed, all other events are acknowledged and ignored
+const (
+	ChargeSucceeded		= "charge.succeeded"
+	ChargeFailed		= "charge.failed"
+	ChargeRefunded		= "charge.refunded"
+	DisputeCreated		= "charge.dispute.created"
+)
+
+// Event is a notification of the payment provider
+type Event struct {
+	Id				string			`json:"id"`
+	Type			string			`json:"type"`
+	Data			struct {
+		Object			Object			`json:"object"`
+	}								`json:"data"`
+}
+
+// Object is the charge or the dispute an event is about
+type Object struct {
+	// the reference of the charge, or the id of the dispute
+	Id				string				`json:"id"`
+	// the reference of the disputed charge, only set for disputes
+	Charge			string				`json:"charge"`
+	Amount			int64				`json:"amount"`
+	AmountRefunded	int64				`json:"amount_refunded"`
+	Currency		string				`json:"currency"`
+	// the payment gateway stores the id of the order as charge_id
+	Metadata		map[string]string	`json:"metadata"`
+}
+
+// Result tells what has been done with an event
+type Result string
+
+const (
+	Processed		Result = "processed"
+	// the event has been delivered before
+	Duplicate		Result = "duplicate"
+	// the event type is not processed, or the event does not belong to a known payment or order
+	Ignored			Result = "ignored"
+)
+
+// Service is the interface that provides the webhook methods
+type Service interface {
+	// processes an event of the payment provider, the signature has already been verified
+	Handle(event *Event) (Result, error)
+}
+
+type service struct {
+	payments		payment.Service
+	orders			order.Service
+	events			idempotencyModel.Repository
+	retention		time.Duration
+}
+
+func (s *service) Handle(event *Event) (Result, error) {
+	if event == nil || event.Id == "" || event.Type == "" {
+		return "", ErrInvalidEvent
+	}
+
+	now := time.Now()
+	record := idempotencyModel.New(eventKeyPrefix + event.Id, "", now.Add(s.retention))
+
+	existing, err := s.events.Begin(record, now)
+
+	if err != nil {
+		return "", err
+	}
+
+	if existing != nil {
+		if !existing.Completed {
+			return "", ErrInProgress
+		}
+		return Duplicate, nil
+	}
+
+	result, err := s.process(event)
+
+	if err != nil {
+		// the provider delivers the event again later
+		s.events.Remove(record.Key)
+		return "", err
+	}
+
+	record.Completed = true
+	if err := s.events.Complete(record); err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+func (s *service) process(event *Event) (Result, error) {
+	obj := event.Data.Object
+	orderId := orderModel.OrderId(obj.Metadata["charge_id"])
+
+	switch event.Type {
+	case ChargeSucceeded:
+		return s.succeeded(orderId, obj)
+	case ChargeFailed:
+		return s.changeOrderStatus(orderId, orderModel.PaymentError, orderModel.Created)
+	case ChargeRefunded:
+		return s.refunded(obj)
+	case DisputeCreated:
+		return s.disputed(obj)
+	}
+
+	return Ignored, nil
+}
+
+// marks the order as paid if the charge has been captured right away, e.g. by a client that charges the order itself
+// authorizations belong to a checkout, which marks the order as paid once the items have been withdrawn and rolls it back
+// otherwise - and an order with a payment error has had its items put back into the stock, so a late success can't make it paid
+// all of these events are ignored, which is logged for a manual follow-up
+func (s *service) succeeded(orderId orderModel.OrderId, obj Object) (Result, error) {
+	if orderId == "" {
+		return Ignored, nil
+	}
+
+	for _, p := range s.payments.GetPaymentsForOrder(orderId) {
+		if p.Reference == obj.Id && p.Captured.IsPositive() {
+			return s.changeOrderStatus(orderId, orderModel.PaymentSuccessful, orderModel.Created)
+		}
+	}
+
+	return Ignored, nil
+}
+
+// records the refund and cancels the order if it has been refunded completely before it has been shipped
+func (s *service) refunded(obj Object) (Result, error) {
+	total, err := amountOf(obj.AmountRefunded, obj.Currency)
+
+	if err != nil {
+		return "", err
+	}
+
+	p, err := s.payments.RecordRefund(obj.Id, total)
+
+	if err == paymentModel.ErrUnknown {
+		return Ignored, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// partial refunds don't change the order
+	if p.Status != paymentModel.Refunded && p.Status != paymentModel.Voided {
+		return Processed, nil
+	}
+
+	o, err := s.orders.GetById(p.OrderId)
+
+	if err == orderModel.ErrUnknown {
+		return Ignored, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// an order without money must not be shipped
+	if o.Status == orderModel.PaymentSuccessful {
+		if _, err := s.orders.Cancel(o.Id, "The payment has been refunded", serviceName); err != nil && err != orderModel.ErrInvalidOperation {
+			return "", err
+		}
+	}
+
+	return Processed, nil
+}
+
+// records the dispute and marks the order, so it is not shipped until the dispute has been settled
+func (s *service) disputed(obj Object) (Result, error) {
+	amount, err := amountOf(obj.Amount, obj.Currency)
+
+	if err != nil {
+		return "", err
+	}
+
+	p, err := s.payments.RecordDispute(obj.Charge, amount)
+
+	// a payment that can't be disputed has already been disputed, or it belongs to someone else
+	if err == paymentModel.ErrUnknown || err == paymentModel.ErrInvalidOperation {
+		return Ignored, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return s.changeOrderStatus(p.OrderId, orderModel.PaymentDisputed, orderModel.PaymentSuccessful, orderModel.Shipped)
+}
+
+// moves the order on to the next status if it currently has one of the expected statuses
+func (s *service) changeOrderStatus(id orderModel.OrderId, next orderModel.OrderStatus, expected ...orderModel.OrderStatus) (Result, error) {
+	if id == "" {
+		return Ignored, nil
+	}
+
+	o, err := s.orders.GetById(id)
+
+	if err == orderModel.ErrUnknown {
+		return Ignored, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, status := range expected {
+		if o.Status != status {
+			continue
+		}
+
+		_, err := s.orders.UpdateStatus(id, next, serviceName)
+
+		// the order has been changed in the meantime
+		if err == orderModel.ErrInvalidOperation {
+			return Ignored, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		return Processed, nil
+	}
+
+	return Ignored, nil
+}
+
+// the provider sends amounts in the smallest unit of the currency
+func amountOf(amount int64, currency string) (money.Money, error) {
+	c, err := money.ParseCurrency(currency)
+
+	if err != nil {
+		return money.Money{}, ErrInvalidEvent
+	}
+
+	return money.New(amount, c), nil
+}
+
+// NewService returns a webhook service with the necessary dependencies
+// the ids of processed events are kept for the retention in the repository
+func NewService(payments payment.Service, orders order.Service, events idempotencyModel.Repository, retention time.Duration) Service {
+	return &service{
+		payments:		payments,
+		orders:			orders,
+		events:			events,
+		retention:		retention,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the request header containing the timestamp and the signatures of an event
+// e.g. "t=1492774577,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd"
+const SignatureHeader = "Stripe-Signature"
+
+// DefaultTolerance is used when no tolerance is configured
+const DefaultTolerance = time.Minute * 5
+
+// ErrMissingSignature is returned when an event has no signature header
+var ErrMissingSignature = errors.New("Missing webhook signature")
+
+// ErrInvalidSignature is returned when no signature of an event matches its payload
+var ErrInvalidSignature = errors.New("Invalid webhook signature")
+
+// ErrExpiredSignature is returned when an event has been signed too long ago, so it can't be replayed later on
+var ErrExpiredSignature = errors.New("The webhook signature has expired")
+
+// Sign returns the signature of the payload sent at the timestamp - the hex encoded HMAC-SHA256 of "timestamp.payload"
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checks that one of the signatures in the header belongs to the payload and that it has been created within the tolerance
+// the provider may send several signatures while the secret is rotated
+func verifySignature(header string, payload []byte, secret string, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	// without a secret no signature can be trusted
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+
+	var timestamp int64
+	signatures := []string{}
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			t, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = t
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected, _ := hex.DecodeString(Sign(secret, timestamp, payload))
+
+	valid := false
+	for _, signature := range signatures {
+		actual, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(expected, actual) {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	// the timestamp is part of the signed payload, so it can be trusted once the signature has been checked
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
\ No newline at end of file
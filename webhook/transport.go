@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	kitlog "github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"encoding/json"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"github.com/gorilla/mux"
+)
+
+// events are small, larger bodies are not read completely
+const maxEventSize = 1 << 20
+
+// MakeHandler returns a handler for the webhook service
+// the events are authenticated by their signature with the shared secret instead of a JWT auth token
+func MakeHandler(ws Service, secret string, tolerance time.Duration, logger kitlog.Logger) http.Handler {
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+	}
+
+	handleHandler := kithttp.NewServer(
+		makeHandleEndpoint(ws),
+		makeDecodeHandleRequest(secret, tolerance),
+		encodeResponse,
+		opts...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/payment/webhook", handleHandler).Methods("POST")
+
+	return r
+}
+
+// the signature is calculated over the raw body, so it is checked before the event is decoded
+func makeDecodeHandleRequest(secret string, tolerance time.Duration) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxEventSize))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifySignature(r.Header.Get(SignatureHeader), payload, secret, tolerance, time.Now()); err != nil {
+			return nil, err
+		}
+
+		var event Event
+
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, ErrInvalidEvent
+		}
+
+		return handleRequest{
+			Event:		&event,
+		}, nil
+	}
+}
+
+// encode the JSON response
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(erroer); ok && e.error() != nil {
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+type erroer interface {
+	error() error
+}
+
+// encode errors from business logic
+// the provider delivers events again that have not been answered with a success status
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	case ErrInvalidEvent:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrMissingSignature:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidSignature:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrExpiredSignature:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInProgress:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
\ No newline at end of file